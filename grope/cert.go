@@ -0,0 +1,322 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/aberic/gnomon"
+	"github.com/aberic/gnomon/log"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ClientAuthNone 不要求客户端证书
+	ClientAuthNone ClientAuth = iota
+	// ClientAuthRequest 请求客户端证书，客户端提供时必须通过CA验证
+	ClientAuthRequest
+	// ClientAuthRequire 要求客户端提供通过CA验证的证书
+	ClientAuthRequire
+)
+
+// certCheckInterval 默认检查证书文件变更的间隔
+const certCheckInterval = 10 * time.Second
+
+var (
+	// ErrCertNotFound no certificate configured
+	ErrCertNotFound = errors.New("no certificate configured")
+	// ErrCertClientCA client auth requires ca certificates
+	ErrCertClientCA = errors.New("client auth requires ca certificates")
+	// ErrCertCAInvalid no valid ca certificate found in pem
+	ErrCertCAInvalid = errors.New("no valid ca certificate found in pem")
+)
+
+// ClientAuth 客户端证书验证策略
+type ClientAuth int
+
+// tlsClientAuth 转换为 tls.ClientAuthType
+func (ca ClientAuth) tlsClientAuth() tls.ClientAuthType {
+	switch ca {
+	case ClientAuthRequest:
+		return tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert
+	}
+	return tls.NoClientCert
+}
+
+// NewCertManager 新建证书管理器，首个证书作为未匹配SNI时的默认证书
+//
+// 必须提供包含证书和与服务器匹配的私钥的文件。如果证书是由证书颁发机构签署的，则certFile应该是服务器证书、任何中间体和CA证书的连接。
+func NewCertManager(certFilePath, keyFilePath string) (*CertManager, error) {
+	cm := &CertManager{CheckInterval: certCheckInterval, names: map[string]*certEntry{}}
+	if err := cm.AddCertificate(certFilePath, keyFilePath); nil != err {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// CertManager 证书管理器，支持按SNI选择证书、证书文件变更后自动重新加载及客户端证书验证
+//
+// 证书文件的变更在TLS握手时检查，无需额外的协程
+type CertManager struct {
+	CheckInterval time.Duration // 检查证书文件变更的间隔，默认10秒，小于0表示不自动检查
+
+	certs      []*certEntry          // 按添加顺序排列的证书，首个为默认证书
+	names      map[string]*certEntry // SNI主机名与证书映射，支持“*.example.com”
+	cas        []*certFile           // 用于验证客户端证书的CA证书文件
+	clientCAs  *x509.CertPool        // 用于验证客户端证书的CA证书池
+	clientAuth ClientAuth            // 客户端证书验证策略
+	config     *tls.Config           // 当前握手使用的TLS配置
+	checked    time.Time             // 最后一次检查证书文件变更的时间
+	lock       sync.RWMutex
+}
+
+// certFile 证书文件及其变更标识
+type certFile struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+// changed 文件是否已变更，变更时记录新的变更标识
+func (cf *certFile) changed() bool {
+	info, err := os.Stat(cf.path)
+	if nil != err || (info.ModTime().Equal(cf.modTime) && info.Size() == cf.size) {
+		return false
+	}
+	cf.modTime, cf.size = info.ModTime(), info.Size()
+	return true
+}
+
+// read 读取文件内容并记录变更标识
+func (cf *certFile) read() ([]byte, error) {
+	cf.changed()
+	return ioutil.ReadFile(cf.path)
+}
+
+// certEntry 证书及其来源文件
+type certEntry struct {
+	certFile    *certFile
+	keyFile     *certFile
+	serverNames []string
+	cert        *tls.Certificate
+}
+
+// load 从文件加载证书
+func (ce *certEntry) load() error {
+	certPEM, err := ce.certFile.read()
+	if nil != err {
+		return err
+	}
+	keyPEM, err := ce.keyFile.read()
+	if nil != err {
+		return err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if nil != err {
+		return err
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); nil != err {
+		return err
+	}
+	ce.cert = &cert
+	return nil
+}
+
+// AddCertificate 添加证书
+//
+// serverNames 证书对应的SNI主机名，如“example.com”或“*.example.com”，为空时使用证书中的DNS名称
+func (cm *CertManager) AddCertificate(certFilePath, keyFilePath string, serverNames ...string) error {
+	entry := &certEntry{certFile: &certFile{path: certFilePath}, keyFile: &certFile{path: keyFilePath}}
+	if err := entry.load(); nil != err {
+		return err
+	}
+	if len(serverNames) == 0 {
+		serverNames = entry.cert.Leaf.DNSNames
+	}
+	entry.serverNames = serverNames
+	defer cm.lock.Unlock()
+	cm.lock.Lock()
+	cm.certs = append(cm.certs, entry)
+	for _, serverName := range serverNames {
+		cm.names[strings.ToLower(serverName)] = entry
+	}
+	cm.config = nil
+	return nil
+}
+
+// SetClientAuth 设置客户端证书验证策略
+//
+// caCertFilePaths 用于验证客户端证书的CA证书文件，ClientAuthRequest 及 ClientAuthRequire 时不能为空
+func (cm *CertManager) SetClientAuth(clientAuth ClientAuth, caCertFilePaths ...string) error {
+	if clientAuth != ClientAuthNone && len(caCertFilePaths) == 0 {
+		return ErrCertClientCA
+	}
+	var cas []*certFile
+	for _, caCertFilePath := range caCertFilePaths {
+		cas = append(cas, &certFile{path: caCertFilePath})
+	}
+	pool, err := loadCertPool(cas)
+	if nil != err {
+		return err
+	}
+	defer cm.lock.Unlock()
+	cm.lock.Lock()
+	cm.clientAuth, cm.cas, cm.clientCAs = clientAuth, cas, pool
+	cm.config = nil
+	return nil
+}
+
+// loadCertPool 从文件加载CA证书池
+func loadCertPool(cas []*certFile) (*x509.CertPool, error) {
+	if len(cas) == 0 {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	for _, ca := range cas {
+		caPEM, err := ca.read()
+		if nil != err {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, ErrCertCAInvalid
+		}
+	}
+	return pool, nil
+}
+
+// Reload 立即重新加载全部证书文件，加载失败的证书保持不变
+func (cm *CertManager) Reload() error {
+	defer cm.lock.Unlock()
+	cm.lock.Lock()
+	return cm.reload(true)
+}
+
+// reload 重新加载证书文件，force为false时仅加载已变更的文件
+func (cm *CertManager) reload(force bool) error {
+	var errs []string
+	for _, entry := range cm.certs {
+		certChanged, keyChanged := entry.certFile.changed(), entry.keyFile.changed()
+		if !force && !certChanged && !keyChanged {
+			continue
+		}
+		if err := entry.load(); nil != err {
+			// 证书与私钥可能尚未全部写入，清除变更标识以便下次检查时重试
+			entry.certFile.modTime, entry.keyFile.modTime = time.Time{}, time.Time{}
+			errs = append(errs, gnomon.StringBuild(entry.certFile.path, ": ", err.Error()))
+			continue
+		}
+		cm.config = nil
+	}
+	changed := force
+	for _, ca := range cm.cas {
+		if ca.changed() {
+			changed = true
+		}
+	}
+	if changed && len(cm.cas) > 0 {
+		if pool, err := loadCertPool(cm.cas); nil != err {
+			for _, ca := range cm.cas {
+				ca.modTime = time.Time{}
+			}
+			errs = append(errs, gnomon.StringBuild("client ca: ", err.Error()))
+		} else {
+			cm.clientCAs = pool
+			cm.config = nil
+		}
+	}
+	cm.checked = time.Now()
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// refresh 距上次检查超过 CheckInterval 时重新加载已变更的证书文件
+func (cm *CertManager) refresh() {
+	cm.lock.RLock()
+	due := cm.CheckInterval >= 0 && time.Since(cm.checked) >= cm.CheckInterval
+	cm.lock.RUnlock()
+	if !due {
+		return
+	}
+	defer cm.lock.Unlock()
+	cm.lock.Lock()
+	if time.Since(cm.checked) < cm.CheckInterval { // 其它握手已完成检查
+		return
+	}
+	if err := cm.reload(false); nil != err {
+		log.Error("grope cert reload", log.Err(err))
+	}
+}
+
+// TLSConfig 返回由证书管理器提供证书及客户端验证策略的TLS配置，可用于 Server.TLSConfig 或 tls.NewListener
+func (cm *CertManager) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		NextProtos:         []string{"h2", "http/1.1"},
+		GetCertificate:     cm.getCertificate,
+		GetConfigForClient: cm.getConfigForClient,
+	}
+}
+
+// getCertificate 按SNI主机名选择证书，依次匹配完整主机名、通配主机名及默认证书
+func (cm *CertManager) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cm.refresh()
+	defer cm.lock.RUnlock()
+	cm.lock.RLock()
+	if len(cm.certs) == 0 {
+		return nil, ErrCertNotFound
+	}
+	serverName := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if entry, exist := cm.names[serverName]; exist {
+		return entry.cert, nil
+	}
+	if index := strings.Index(serverName, "."); index > 0 {
+		if entry, exist := cm.names[gnomon.StringBuild("*", serverName[index:])]; exist {
+			return entry.cert, nil
+		}
+	}
+	return cm.certs[0].cert, nil
+}
+
+// getConfigForClient 返回当前客户端验证策略及CA证书池对应的TLS配置
+func (cm *CertManager) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	cm.refresh()
+	cm.lock.RLock()
+	config := cm.config
+	cm.lock.RUnlock()
+	if nil != config {
+		return config, nil
+	}
+	defer cm.lock.Unlock()
+	cm.lock.Lock()
+	if nil == cm.config {
+		cm.config = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			NextProtos:     []string{"h2", "http/1.1"},
+			GetCertificate: cm.getCertificate,
+			ClientAuth:     cm.clientAuth.tlsClientAuth(),
+			ClientCAs:      cm.clientCAs,
+		}
+	}
+	return cm.config, nil
+}
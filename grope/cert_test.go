@@ -0,0 +1,177 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "grope test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if nil != err {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue 签发证书并写入dir，返回证书及私钥文件路径
+func (ca *testCA) issue(t *testing.T, dir, name string, serial int64, client bool, dnsNames ...string) (string, string) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     dnsNames,
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if client {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if nil != err {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	certFilePath, keyFilePath := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	_ = ioutil.WriteFile(certFilePath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	_ = ioutil.WriteFile(keyFilePath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFilePath, keyFilePath
+}
+
+func TestCertManagerSNIAndReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	defaultCert, defaultKey := ca.issue(t, dir, "default", 10, false, "default.example.com")
+	wildcardCert, wildcardKey := ca.issue(t, dir, "wildcard", 20, false, "*.example.org")
+	cm, err := NewCertManager(defaultCert, defaultKey)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if err = cm.AddCertificate(wildcardCert, wildcardKey); nil != err {
+		t.Fatal(err)
+	}
+	cm.CheckInterval = 0
+
+	serial := func(serverName string) int64 {
+		cert, err := cm.TLSConfig().GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+		if nil != err {
+			t.Fatal(err)
+		}
+		return cert.Leaf.SerialNumber.Int64()
+	}
+	if got := serial("api.example.org"); got != 20 {
+		t.Fatalf("wildcard serial = %d", got)
+	}
+	if got := serial("unknown.example.net"); got != 10 {
+		t.Fatalf("default serial = %d", got)
+	}
+
+	ca.issue(t, dir, "wildcard", 21, false, "*.example.org")
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(wildcardCert, future, future)
+	_ = os.Chtimes(wildcardKey, future, future)
+	if got := serial("api.example.org"); got != 21 {
+		t.Fatalf("reloaded serial = %d", got)
+	}
+}
+
+func TestCertManagerClientAuth(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, dir, "server", 10, false, "localhost")
+	clientCert, clientKey := ca.issue(t, dir, "client", 11, true)
+	caFilePath := filepath.Join(dir, "ca.crt")
+	_ = ioutil.WriteFile(caFilePath, ca.pem, 0600)
+
+	cm, err := NewCertManager(serverCert, serverKey)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if err = cm.SetClientAuth(ClientAuthRequire); err != ErrCertClientCA {
+		t.Fatalf("err = %v", err)
+	}
+	if err = cm.SetClientAuth(ClientAuthRequire, caFilePath); nil != err {
+		t.Fatal(err)
+	}
+
+	hs := NewHTTPServe()
+	_ = hs.Group("/tls").Get("/whoami", func(ctx *Context) {
+		_ = ctx.ResponseText(http.StatusOK, ctx.ClientIdentity())
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	server := NewServer("", hs)
+	go func() { _ = server.Serve(tls.NewListener(listener, cm.TLSConfig())) }()
+	defer func() { _ = server.Close() }()
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.pem)
+	get := func(certificates ...tls.Certificate) (string, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, Certificates: certificates}}}
+		resp, err := client.Get("https://" + listener.Addr().String() + "/tls/whoami")
+		if nil != err {
+			return "", err
+		}
+		defer func() { _ = resp.Body.Close() }()
+		bs, err := ioutil.ReadAll(resp.Body)
+		return string(bs), err
+	}
+	if _, err = get(); nil == err {
+		t.Fatal("expected handshake failure without client certificate")
+	}
+	pair, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if identity, err := get(pair); nil != err || identity != "client" {
+		t.Fatalf("identity = %q, err = %v", identity, err)
+	}
+}
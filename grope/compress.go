@@ -0,0 +1,353 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/aberic/gnomon"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// encodingGzip gzip压缩
+	encodingGzip = "gzip"
+	// encodingDeflate deflate压缩，按 RFC 7230 使用zlib格式
+	encodingDeflate = "deflate"
+	// compressDefaultMinSize 默认最小压缩字节数
+	compressDefaultMinSize = 1024
+)
+
+// compressDefaultExcludedTypes 默认不压缩的内容类型，以“/”结尾时匹配该大类下的全部类型
+var compressDefaultExcludedTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-compress",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/x-bzip2", "application/x-xz",
+}
+
+// CompressConfig 响应压缩策略
+type CompressConfig struct {
+	Level         int      // 压缩级别，0表示 gzip.DefaultCompression
+	MinSize       int      // 最小压缩字节数，小于该值的响应不压缩，0表示1024，流式响应刷新时不受该值限制
+	ExcludedTypes []string // 不压缩的内容类型，为空时使用默认列表（图片、音视频及压缩包等），以“/”结尾时匹配该大类，“image/svg+xml”始终可压缩
+}
+
+// excluded 内容类型是否不压缩
+func (cc *CompressConfig) excluded(contentType string) bool {
+	contentType = strings.ToLower(filterFlags(contentType))
+	if contentType == "image/svg+xml" {
+		return false
+	}
+	for _, excluded := range cc.ExcludedTypes {
+		if strings.HasPrefix(contentType, excluded) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriterPool 压缩器对象池
+type compressWriterPool struct {
+	gzip sync.Pool
+	zlib sync.Pool
+}
+
+// Compress 响应压缩过滤器/拦截器，根据“Accept-Encoding”选择gzip或deflate压缩响应内容
+//
+// 响应内容小于最小压缩字节数、内容类型不压缩、已设置“Content-Encoding”或为部分内容响应时不压缩
+//
+// 流式响应（Stream 及 SSE）在每次刷新时同步刷新压缩数据；websocket等被劫持的连接不受影响
+func Compress(config *CompressConfig) Filter {
+	cc := &CompressConfig{Level: gzip.DefaultCompression, MinSize: compressDefaultMinSize, ExcludedTypes: compressDefaultExcludedTypes}
+	if nil != config {
+		if config.Level != 0 {
+			cc.Level = config.Level
+		}
+		if config.MinSize > 0 {
+			cc.MinSize = config.MinSize
+		}
+		if len(config.ExcludedTypes) > 0 {
+			cc.ExcludedTypes = config.ExcludedTypes
+		}
+	}
+	pool := &compressWriterPool{}
+	return func(ctx *Context) {
+		ctx.writer.Header().Add("Vary", "Accept-Encoding")
+		encoding := acceptEncoding(ctx.HeaderGet("Accept-Encoding"))
+		if gnomon.StringIsEmpty(encoding) || ctx.request.Method == http.MethodHead || ctx.IsWebsocket() {
+			return
+		}
+		writer := ctx.writer
+		cw := &compressWriter{ResponseWriter: writer, config: cc, pool: pool, encoding: encoding}
+		ctx.SetWriter(cw)
+		defer func() {
+			cw.close()
+			ctx.SetWriter(writer)
+		}()
+		ctx.Next()
+	}
+}
+
+// acceptEncoding 根据“Accept-Encoding”选择压缩方式，权重相同时优先gzip
+func acceptEncoding(header string) string {
+	var encoding string
+	var weight float64
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+		for _, param := range params[1:] {
+			if param = strings.TrimSpace(param); strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); nil == err {
+					q = value
+				}
+			}
+		}
+		if name == "*" {
+			name = encodingGzip
+		}
+		if (name == encodingGzip || name == encodingDeflate) && q > 0 &&
+			(q > weight || (q == weight && name == encodingGzip)) {
+			encoding, weight = name, q
+		}
+	}
+	return encoding
+}
+
+// compressWriter 压缩响应内容的 http.ResponseWriter
+//
+// 响应内容在达到最小压缩字节数、刷新或请求处理结束前缓存，以确定是否压缩
+type compressWriter struct {
+	http.ResponseWriter
+	config   *CompressConfig
+	pool     *compressWriterPool
+	encoding string
+	status   int            // 缓存的状态码
+	buffer   []byte         // 确定是否压缩前缓存的响应内容
+	decided  bool           // 是否已确定是否压缩
+	writer   io.WriteCloser // 压缩器，nil表示不压缩
+	hijacked bool
+}
+
+// WriteHeader 缓存状态码，确定是否压缩后写入
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if cw.decided {
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if statusCode < http.StatusOK { // 1xx 信息响应直接写入
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if cw.status == 0 {
+		cw.status = statusCode
+	}
+	if length, err := strconv.Atoi(cw.Header().Get("Content-Length")); nil == err && length < cw.config.MinSize {
+		_ = cw.decide(false)
+	}
+}
+
+// Write 写入响应内容
+func (cw *compressWriter) Write(bytes []byte) (int, error) {
+	if !cw.decided {
+		cw.buffer = append(cw.buffer, bytes...)
+		if len(cw.buffer) < cw.config.MinSize {
+			return len(bytes), nil
+		}
+		if err := cw.decide(true); nil != err {
+			return 0, err
+		}
+		return len(bytes), nil
+	}
+	if nil != cw.writer {
+		return cw.writer.Write(bytes)
+	}
+	return cw.ResponseWriter.Write(bytes)
+}
+
+// Flush 将缓存及压缩数据刷新至客户端
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		_ = cw.decide(true)
+	}
+	if flusher, ok := cw.writer.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack 接管连接，接管后不再压缩
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, ErrResponseHijacker
+	}
+	cw.hijacked = true
+	return hijacker.Hijack()
+}
+
+// Unwrap 返回原始 http.ResponseWriter，供 http.ResponseController 使用
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// decide 确定是否压缩并写入状态码及缓存的响应内容
+//
+// compress 为false时不压缩，为true时仍需满足内容类型等条件
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+	header := cw.Header()
+	if gnomon.StringIsEmpty(header.Get("Content-Type")) && len(cw.buffer) > 0 {
+		header.Set("Content-Type", http.DetectContentType(cw.buffer))
+	}
+	status := cw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if compress && cw.compressible(status) {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", cw.encoding)
+		cw.writer = cw.acquire()
+	}
+	cw.ResponseWriter.WriteHeader(status)
+	buffer := cw.buffer
+	cw.buffer = nil
+	if len(buffer) == 0 {
+		return nil
+	}
+	var err error
+	if nil != cw.writer {
+		_, err = cw.writer.Write(buffer)
+	} else {
+		_, err = cw.ResponseWriter.Write(buffer)
+	}
+	return err
+}
+
+// compressible 响应是否可压缩
+func (cw *compressWriter) compressible(status int) bool {
+	header := cw.Header()
+	return status != http.StatusNoContent && status != http.StatusNotModified && status != http.StatusPartialContent &&
+		gnomon.StringIsEmpty(header.Get("Content-Encoding")) && gnomon.StringIsEmpty(header.Get("Content-Range")) &&
+		!cw.config.excluded(header.Get("Content-Type"))
+}
+
+// acquire 从对象池获取压缩器
+func (cw *compressWriter) acquire() io.WriteCloser {
+	switch cw.encoding {
+	case encodingGzip:
+		if gw, ok := cw.pool.gzip.Get().(*gzip.Writer); ok {
+			gw.Reset(cw.ResponseWriter)
+			return gw
+		}
+		gw, err := gzip.NewWriterLevel(cw.ResponseWriter, cw.config.Level)
+		if nil != err {
+			gw = gzip.NewWriter(cw.ResponseWriter)
+		}
+		return gw
+	default:
+		if zw, ok := cw.pool.zlib.Get().(*zlib.Writer); ok {
+			zw.Reset(cw.ResponseWriter)
+			return zw
+		}
+		zw, err := zlib.NewWriterLevel(cw.ResponseWriter, cw.config.Level)
+		if nil != err {
+			zw = zlib.NewWriter(cw.ResponseWriter)
+		}
+		return zw
+	}
+}
+
+// close 请求处理结束，写入剩余缓存并关闭压缩器
+func (cw *compressWriter) close() {
+	if cw.hijacked {
+		return
+	}
+	if !cw.decided {
+		if cw.status == 0 && len(cw.buffer) == 0 { // 未写入任何响应，交由后续流程处理
+			return
+		}
+		_ = cw.decide(false)
+	}
+	if nil == cw.writer {
+		return
+	}
+	_ = cw.writer.Close()
+	switch writer := cw.writer.(type) {
+	case *gzip.Writer:
+		cw.pool.gzip.Put(writer)
+	case *zlib.Writer:
+		cw.pool.zlib.Put(writer)
+	}
+	cw.writer = nil
+}
+
+// Decompress 请求解压过滤器/拦截器，透明解压“Content-Encoding”为gzip或deflate的请求内容，使 tune.Parse* 等方法可直接读取
+//
+// maxBytes 解压后允许的最大字节数，超出时读取请求内容返回错误，0表示不限
+//
+// 解压数据格式错误时应答 400 Bad Request
+func Decompress(maxBytes int64) Filter {
+	return func(ctx *Context) {
+		var (
+			reader io.ReadCloser
+			err    error
+		)
+		switch strings.ToLower(strings.TrimSpace(ctx.HeaderGet("Content-Encoding"))) {
+		case encodingGzip, "x-gzip":
+			reader, err = gzip.NewReader(ctx.request.Body)
+		case encodingDeflate:
+			reader, err = zlib.NewReader(ctx.request.Body)
+		default:
+			return
+		}
+		if nil != err {
+			ctx.Abort()
+			_ = ctx.ResponseText(http.StatusBadRequest, gnomon.StringBuild("invalid request body encoding: ", err.Error()))
+			return
+		}
+		body := ctx.request.Body
+		ctx.request.Body = &decompressReader{ReadCloser: reader, body: body}
+		if maxBytes > 0 {
+			ctx.request.Body = http.MaxBytesReader(ctx.writer, ctx.request.Body, maxBytes)
+		}
+		ctx.request.Header.Del("Content-Encoding")
+		ctx.request.Header.Del("Content-Length")
+		ctx.request.ContentLength = -1
+	}
+}
+
+// decompressReader 关闭时同时关闭原始请求内容的解压读取器
+type decompressReader struct {
+	io.ReadCloser
+	body io.ReadCloser
+}
+
+// Close 关闭解压读取器及原始请求内容
+func (dr *decompressReader) Close() error {
+	err := dr.ReadCloser.Close()
+	if bodyErr := dr.body.Close(); nil == err {
+		err = bodyErr
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/aberic/gnomon/grope/tune"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("grope", 400)
+	hs := NewHTTPServe(Compress(nil), Decompress(1<<20))
+	route := hs.Group("/compress")
+	_ = route.Get("/large", func(ctx *Context) {
+		_ = ctx.ResponseJSON(http.StatusOK, &negotiateModel{Name: large})
+	})
+	_ = route.Get("/small", func(ctx *Context) {
+		_ = ctx.ResponseJSON(http.StatusOK, &negotiateModel{Name: "small"})
+	})
+	_ = route.Get("/image", func(ctx *Context) {
+		ctx.HeaderSet("Content-Type", "image/png")
+		_, _ = ctx.Writer().Write([]byte(large))
+	})
+	_ = route.Get("/stream", func(ctx *Context) {
+		count := 0
+		ctx.Stream(func(w io.Writer) bool {
+			_, _ = io.WriteString(w, "chunk;")
+			count++
+			return count < 3
+		})
+	})
+	_ = route.Post("/echo", func(ctx *Context) {
+		model := &negotiateModel{}
+		if err := ctx.ReceiveJSON(model); nil != err {
+			_ = ctx.ResponseText(http.StatusBadRequest, err.Error())
+			return
+		}
+		_ = ctx.ResponseText(http.StatusOK, model.Name)
+	})
+
+	cases := []struct {
+		path     string
+		accept   string
+		encoding string
+		body     string
+	}{
+		{"/compress/large", "gzip, deflate", "gzip", `{"name":"` + large + `"}`},
+		{"/compress/large", "gzip;q=0.5, deflate", "deflate", `{"name":"` + large + `"}`},
+		{"/compress/large", "br", "", `{"name":"` + large + `"}`},
+		{"/compress/small", "gzip", "", `{"name":"small"}`},
+		{"/compress/image", "gzip", "", large},
+		{"/compress/stream", "gzip", "gzip", "chunk;chunk;chunk;"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		req.Header.Set("Accept-Encoding", c.accept)
+		rec := httptest.NewRecorder()
+		hs.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Content-Encoding"); got != c.encoding {
+			t.Errorf("%s %q encoding = %q", c.path, c.accept, got)
+			continue
+		}
+		if !strings.Contains(rec.Header().Get("Vary"), "Accept-Encoding") {
+			t.Errorf("%s vary = %q", c.path, rec.Header().Get("Vary"))
+		}
+		var reader io.Reader = rec.Body
+		switch c.encoding {
+		case "gzip":
+			reader, _ = gzip.NewReader(rec.Body)
+		case "deflate":
+			reader, _ = zlib.NewReader(rec.Body)
+		}
+		if body, err := ioutil.ReadAll(reader); nil != err || string(body) != c.body {
+			t.Errorf("%s %q body = %.40s, err = %v", c.path, c.accept, body, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, _ = io.WriteString(gw, `{"name":"gzipped"}`)
+	_ = gw.Close()
+	for body, expect := range map[string]string{buf.String(): "gzipped", "not gzip": ""} {
+		req := httptest.NewRequest(http.MethodPost, "/compress/echo", strings.NewReader(body))
+		req.Header.Set("Content-Type", tune.ContentTypeJSON)
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		hs.ServeHTTP(rec, req)
+		if expect != "" && (rec.Code != http.StatusOK || rec.Body.String() != expect) {
+			t.Errorf("decompress = %d %s", rec.Code, rec.Body.String())
+		} else if expect == "" && rec.Code != http.StatusBadRequest {
+			t.Errorf("invalid gzip = %d", rec.Code)
+		}
+	}
+}
@@ -15,11 +15,13 @@
 package grope
 
 import (
+	"crypto/x509"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"github.com/aberic/gnomon"
 	"github.com/aberic/gnomon/grope/tune"
+	"github.com/aberic/gnomon/log"
 	"github.com/golang/protobuf/proto"
 	"github.com/vmihailenco/msgpack"
 	"gopkg.in/yaml.v3"
@@ -30,6 +32,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // Context grope 请求处理上下文
@@ -42,10 +45,128 @@ type Context struct {
 	sameSite http.SameSite
 	// valueMap 如果需要，这里是与url请求中对应的参数集合，如“/demo/:id/”，则通过 valueMap[id] 获取url中的值
 	valueMap map[string]string
-	// paramMap 如果需要，这里是请求params
-	paramMap map[string]string
+	// query 请求params，首次获取时解析
+	query url.Values
 	// responded 已经处理过
 	responded bool
+	// handlers 本次请求的调用链，由过滤器/拦截器及请求处理方法组成
+	handlers []Filter
+	// index 调用链当前执行下标
+	index int
+	// aborted 调用链已被中止
+	aborted bool
+	// keys 本次请求生命周期内的自定义键值存储
+	keys map[string]interface{}
+	// keysLock 自定义键值存储读写锁
+	keysLock sync.RWMutex
+	// contentType 请求未指定内容类型时 Bind 及 Respond 使用的内容类型
+	contentType string
+	// recorder 记录响应状态码及响应内容大小
+	recorder *responseWriter
+	// requestID 本次请求的请求ID
+	requestID string
+	// csrfToken 本次请求的CSRF令牌
+	csrfToken string
+}
+
+// Next 执行调用链中的后续过滤器/拦截器及请求处理方法，仅应在过滤器/拦截器中调用
+//
+// 在 Next 之前的代码会在请求处理方法之前执行，在 Next 之后的代码会在请求处理方法之后执行
+func (c *Context) Next() {
+	c.index++
+	for ; c.index < len(c.handlers); c.index++ {
+		if c.IsAborted() {
+			return
+		}
+		c.handlers[c.index](c)
+	}
+}
+
+// Abort 中止调用链，后续的过滤器/拦截器及请求处理方法不会被执行，但已执行过滤器中 Next 之后的代码仍会执行
+func (c *Context) Abort() {
+	c.aborted = true
+}
+
+// IsAborted 调用链是否已被中止，已有响应写入同样视为中止
+func (c *Context) IsAborted() bool {
+	return c.aborted || c.responded
+}
+
+// Set 在本次请求的上下文中存储自定义键值
+func (c *Context) Set(key string, value interface{}) {
+	defer c.keysLock.Unlock()
+	c.keysLock.Lock()
+	if nil == c.keys {
+		c.keys = map[string]interface{}{}
+	}
+	c.keys[key] = value
+}
+
+// Get 获取本次请求的上下文中存储的自定义值，exist表示是否存在
+func (c *Context) Get(key string) (value interface{}, exist bool) {
+	defer c.keysLock.RUnlock()
+	c.keysLock.RLock()
+	value, exist = c.keys[key]
+	return
+}
+
+// Writer 获取 http.ResponseWriter
+func (c *Context) Writer() http.ResponseWriter {
+	return c.writer
+}
+
+// SetWriter 替换 http.ResponseWriter，可用于在过滤器/拦截器中包装响应以实现响应改写等功能
+func (c *Context) SetWriter(w http.ResponseWriter) {
+	c.writer = w
+}
+
+// StatusCode 获取已写入的响应状态码，尚未写入时返回 200 OK
+func (c *Context) StatusCode() int {
+	if nil == c.recorder || c.recorder.status == 0 {
+		return http.StatusOK
+	}
+	return c.recorder.status
+}
+
+// Size 获取已写入的响应内容字节数
+func (c *Context) Size() int64 {
+	if nil == c.recorder {
+		return 0
+	}
+	return c.recorder.size
+}
+
+// Written 是否已写入响应状态码或响应内容
+func (c *Context) Written() bool {
+	return nil != c.recorder && c.recorder.status != 0
+}
+
+// RequestID 获取本次请求的请求ID，需使用 RequestID 过滤器
+func (c *Context) RequestID() string {
+	return c.requestID
+}
+
+// LogInfo 输出Info级别日志，已设置请求ID时自动附加“request_id”
+func (c *Context) LogInfo(msg string, fields ...log.FieldInter) {
+	log.InfoSkip(3, msg, c.logFields(fields)...)
+}
+
+// LogWarn 输出Warn级别日志，已设置请求ID时自动附加“request_id”
+func (c *Context) LogWarn(msg string, fields ...log.FieldInter) {
+	log.WarnSkip(3, msg, c.logFields(fields)...)
+}
+
+// LogError 输出Error级别日志，已设置请求ID时自动附加“request_id”
+func (c *Context) LogError(msg string, fields ...log.FieldInter) {
+	log.ErrorSkip(3, msg, c.logFields(fields)...)
+}
+
+// logFields 附加请求ID的日志输出对象子集
+func (c *Context) logFields(fields []log.FieldInter) []log.FieldInter {
+	if gnomon.StringIsEmpty(c.requestID) {
+		return fields
+	}
+	return append([]log.FieldInter{log.Field("request_id", c.requestID)}, fields...)
 }
 
 func (c *Context) requestHeader(key string) string {
@@ -113,6 +234,30 @@ func (c *Context) ClientIP() string {
 	return gnomon.IPGet(c.request)
 }
 
+// ClientCertificate 获取经CA验证的客户端证书，未使用TLS或客户端未提供证书时返回nil
+func (c *Context) ClientCertificate() *x509.Certificate {
+	if nil == c.request.TLS || len(c.request.TLS.VerifiedChains) == 0 || len(c.request.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return c.request.TLS.VerifiedChains[0][0]
+}
+
+// ClientIdentity 获取经CA验证的客户端证书身份，依次为证书主题通用名称、首个URI（如SPIFFE ID）、首个邮箱地址，无有效客户端证书时返回空字符串
+func (c *Context) ClientIdentity() string {
+	cert := c.ClientCertificate()
+	switch {
+	case nil == cert:
+		return ""
+	case gnomon.StringIsNotEmpty(cert.Subject.CommonName):
+		return cert.Subject.CommonName
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	}
+	return ""
+}
+
 func filterFlags(content string) string {
 	for i, char := range content {
 		if char == ' ' || char == ';' {
@@ -146,9 +291,14 @@ func (c *Context) Values() map[string]string {
 	return c.valueMap
 }
 
-// Params 获取Params中自定义的参数集合
+// Params 获取Params中自定义的参数集合，同一参数存在多个值时仅保留首个值
 func (c *Context) Params() map[string]string {
-	return c.paramMap
+	query := c.Query()
+	paramMap := make(map[string]string, len(query))
+	for key := range query {
+		paramMap[key] = query.Get(key)
+	}
+	return paramMap
 }
 
 // Value 获取URI中自定义的参数集合中指定Key的值
@@ -156,49 +306,117 @@ func (c *Context) Value(key string) string {
 	return c.valueMap[key]
 }
 
-// Param 获取Params中自定义的参数集合中指定Key的值
+// Param 获取Params中自定义的参数集合中指定Key的首个值
 func (c *Context) Param(key string) string {
-	return c.paramMap[key]
+	return c.Query().Get(key)
+}
+
+// Query 获取请求params，同一参数可包含多个值，无法解析的参数对将被忽略
+func (c *Context) Query() url.Values {
+	if nil == c.query {
+		c.query = c.request.URL.Query()
+	}
+	return c.query
+}
+
+// QueryInt 获取请求params中指定Key的整数值，不存在或无法转换时返回def
+func (c *Context) QueryInt(key string, def int) int {
+	if value, err := strconv.Atoi(c.Query().Get(key)); nil == err {
+		return value
+	}
+	return def
+}
+
+// QueryBool 获取请求params中指定Key的布尔值，仅有参数名（如“?debug”）时视为true，不存在或无法转换时返回def
+func (c *Context) QueryBool(key string, def bool) bool {
+	values, exist := c.Query()[key]
+	if !exist || len(values) == 0 {
+		return def
+	}
+	if gnomon.StringIsEmpty(values[0]) {
+		return true
+	}
+	if value, err := strconv.ParseBool(values[0]); nil == err {
+		return value
+	}
+	return def
+}
+
+// QuerySlice 获取请求params中指定Key的全部值，如“?id=1&id=2”，单个值中以“,”分隔的内容同样会被拆分
+func (c *Context) QuerySlice(key string) []string {
+	var slice []string
+	for _, value := range c.Query()[key] {
+		for _, item := range strings.Split(value, ",") {
+			if gnomon.StringIsNotEmpty(item) {
+				slice = append(slice, item)
+			}
+		}
+	}
+	return slice
+}
+
+// BindQuery 按“query”标签将请求params绑定至model，参数不存在时使用“default”标签中的默认值，绑定后按“validate”标签校验
+//
+// 如“Page int `query:"page" default:"1"`”，转换失败时返回 *tune.BindError
+func (c *Context) BindQuery(model interface{}) error {
+	if err := tune.BindValues(model, "query", c.Query()); nil != err {
+		return err
+	}
+	return tune.ValidateStruct(model)
+}
+
+// BindPath 按“path”标签将URI中自定义的参数绑定至model，参数不存在时使用“default”标签中的默认值，绑定后按“validate”标签校验
+//
+// 如“/demo/:id”对应“ID int64 `path:"id"`”，转换失败时返回 *tune.BindError
+func (c *Context) BindPath(model interface{}) error {
+	values := make(map[string][]string, len(c.valueMap))
+	for key, value := range c.valueMap {
+		values[key] = []string{value}
+	}
+	if err := tune.BindValues(model, "path", values); nil != err {
+		return err
+	}
+	return tune.ValidateStruct(model)
 }
 
-// ReceiveJSON 接收一个"application/json"请求
+// ReceiveJSON 接收一个"application/json"请求，解析后按“validate”标签校验，校验不通过时返回 tune.ValidationErrors
 func (c *Context) ReceiveJSON(model interface{}) error {
 	if err := tune.ParseJSON(c.request, model); nil != err {
 		return err
 	}
-	return nil
+	return tune.ValidateStruct(model)
 }
 
-// ReceiveXML 接收一个"application/xml"请求
+// ReceiveXML 接收一个"application/xml"请求，解析后按“validate”标签校验
 func (c *Context) ReceiveXML(model interface{}) error {
 	if err := tune.ParseXML(c.request, model); nil != err {
 		return err
 	}
-	return nil
+	return tune.ValidateStruct(model)
 }
 
-// ReceiveYaml 接收一个"application/x-yaml"请求
+// ReceiveYaml 接收一个"application/x-yaml"请求，解析后按“validate”标签校验
 func (c *Context) ReceiveYaml(model interface{}) error {
 	if err := tune.ParseYaml(c.request, model); nil != err {
 		return err
 	}
-	return nil
+	return tune.ValidateStruct(model)
 }
 
-// ReceiveMsgPack 接收一个"application/x-msgpack"请求
+// ReceiveMsgPack 接收一个"application/x-msgpack"请求，解析后按“validate”标签校验
 func (c *Context) ReceiveMsgPack(model interface{}) error {
 	if err := tune.ParseMsgPack(c.request, model); nil != err {
 		return err
 	}
-	return nil
+	return tune.ValidateStruct(model)
 }
 
-// ReceiveProtoBuf 接收一个"application/x-protobuf"请求
+// ReceiveProtoBuf 接收一个"application/x-protobuf"请求，解析后按“validate”标签校验
 func (c *Context) ReceiveProtoBuf(pm proto.Message) error {
 	if err := tune.ParseProtoBuf(c.request, pm); nil != err {
 		return err
 	}
-	return nil
+	return tune.ValidateStruct(pm)
 }
 
 // ReceiveForm 接收一个"application/x-www-form-urlencoded"请求
@@ -227,7 +445,7 @@ func (c *Context) response(bytes []byte) error {
 //
 // statusCode eg:http.StatusOK
 func (c *Context) ResponseJSON(statusCode int, model interface{}) error {
-	if err := tune.ValidateStruct(model); nil != err {
+	if err := tune.CheckStruct(model); nil != err {
 		return err
 	}
 	c.responded = true
@@ -244,7 +462,7 @@ func (c *Context) ResponseJSON(statusCode int, model interface{}) error {
 //
 // statusCode eg:http.StatusOK
 func (c *Context) ResponseXML(statusCode int, model interface{}) error {
-	if err := tune.ValidateStruct(model); nil != err {
+	if err := tune.CheckStruct(model); nil != err {
 		return err
 	}
 	c.responded = true
@@ -261,7 +479,7 @@ func (c *Context) ResponseXML(statusCode int, model interface{}) error {
 //
 // statusCode eg:http.StatusOK
 func (c *Context) ResponseYaml(statusCode int, model interface{}) error {
-	if err := tune.ValidateStruct(model); nil != err {
+	if err := tune.CheckStruct(model); nil != err {
 		return err
 	}
 	c.responded = true
@@ -278,7 +496,7 @@ func (c *Context) ResponseYaml(statusCode int, model interface{}) error {
 //
 // statusCode eg:http.StatusOK
 func (c *Context) ResponseMsgPack(statusCode int, model interface{}) error {
-	if err := tune.ValidateStruct(model); nil != err {
+	if err := tune.CheckStruct(model); nil != err {
 		return err
 	}
 	c.responded = true
@@ -0,0 +1,201 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"github.com/aberic/gnomon/grope/tune"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContextNext(t *testing.T) {
+	var trace []string
+	mark := func(name string) Filter {
+		return func(ctx *Context) {
+			trace = append(trace, name+">")
+			ctx.Next()
+			trace = append(trace, "<"+name)
+		}
+	}
+	legacy := func(ctx *Context) { trace = append(trace, "legacy") }
+	hs := NewHTTPServe(mark("serve"))
+	route := hs.Group("/chain", mark("group"))
+	route.Get("/ok", func(ctx *Context) {
+		value, _ := ctx.Get("user")
+		trace = append(trace, "handler:"+value.(string))
+		_ = ctx.ResponseText(http.StatusOK, "ok")
+	}, legacy, func(ctx *Context) { ctx.Set("user", "aberic") })
+	route.Get("/abort", func(ctx *Context) {
+		trace = append(trace, "handler")
+	}, func(ctx *Context) {
+		ctx.Abort()
+		ctx.Status(http.StatusUnauthorized)
+	})
+	route.Get("/responded", func(ctx *Context) {
+		trace = append(trace, "handler")
+	}, func(ctx *Context) {
+		_ = ctx.ResponseText(http.StatusForbidden, "filter")
+	})
+
+	cases := []struct {
+		path   string
+		status int
+		trace  string
+	}{
+		{"/chain/ok", http.StatusOK, "serve> group> legacy handler:aberic <group <serve"},
+		{"/chain/abort", http.StatusUnauthorized, "serve> group> <group <serve"},
+		{"/chain/responded", http.StatusForbidden, "serve> group> <group <serve"},
+	}
+	for _, c := range cases {
+		trace = nil
+		rec := httptest.NewRecorder()
+		hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if rec.Code != c.status {
+			t.Errorf("%s status = %d", c.path, rec.Code)
+		}
+		if got := strings.Join(trace, " "); got != c.trace {
+			t.Errorf("%s trace = %s", c.path, got)
+		}
+	}
+}
+
+type validateAddress struct {
+	City string `validate:"required"`
+}
+
+type validateItem struct {
+	Name string `validate:"required,max=4"`
+}
+
+type validateUser struct {
+	Name    string           `json:"name" validate:"required,min=2,max=8"`
+	Email   string           `json:"email" validate:"email"`
+	Role    string           `json:"role" validate:"oneof=admin user"`
+	Age     int              `json:"age" validate:"min=18"`
+	Code    string           `json:"code" validate:"regexp=^[a-z]{2,3}$"`
+	Address *validateAddress `json:"address"`
+	Items   []*validateItem  `json:"items" validate:"max=2"`
+	Skip    *validateAddress `json:"skip" validate:"-"`
+}
+
+func TestContextReceiveValidate(t *testing.T) {
+	var fields []string
+	hs := NewHTTPServe()
+	_ = hs.Group("/validate").Post("/user", func(ctx *Context) {
+		fields = nil
+		err := ctx.ReceiveJSON(&validateUser{})
+		if errs, ok := err.(tune.ValidationErrors); ok {
+			for _, fe := range errs {
+				fields = append(fields, fe.Field+":"+fe.Rule)
+			}
+		} else if nil != err {
+			t.Error(err)
+		}
+	})
+	cases := []struct {
+		body   string
+		fields string
+	}{
+		{`{"name":"aberic","email":"a@b.com","role":"admin","age":18,"code":"ab","address":{"city":"x"},"items":[{"name":"a"}],"skip":{}}`, ""},
+		{`{"name":"aberic","age":20}`, ""},
+		{`{}`, "Name:required"},
+		{`{"name":"a","email":"a@","role":"root","age":17,"code":"abcd"}`, "Name:min Email:email Role:oneof Age:min Code:regexp"},
+		{`{"name":"aberic","age":18,"address":{},"items":[{"name":"ok"},{"name":"toolong"},{}]}`, "Address.City:required Items:max Items[1].Name:max Items[2].Name:required"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/validate/user", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", tune.ContentTypeJSON)
+		hs.ServeHTTP(httptest.NewRecorder(), req)
+		if got := strings.Join(fields, " "); got != c.fields {
+			t.Errorf("%s fields = %s", c.body, got)
+		}
+	}
+}
+
+type queryModel struct {
+	Page    int           `query:"page" default:"1"`
+	Size    *int          `query:"size"`
+	Tags    []string      `query:"tag" default:"a,b"`
+	Timeout time.Duration `query:"timeout" default:"1s"`
+	Debug   bool          `query:"debug"`
+	Name    string        `query:"name" validate:"max=3"`
+	IDs     []int         `query:"ids"`
+}
+
+type pathModel struct {
+	ID   int64  `path:"id" validate:"required,min=1"`
+	Kind string `path:"kind" default:"all"`
+}
+
+func TestContextQuery(t *testing.T) {
+	var (
+		model    queryModel
+		bindErr  error
+		path     pathModel
+		pathErr  error
+		getters  []interface{}
+		matchURI string
+	)
+	hs := NewHTTPServe()
+	route := hs.Group("/query")
+	_ = route.Get("/items/:id", func(ctx *Context) {
+		matchURI = ctx.Request().URL.Path
+		getters = []interface{}{ctx.Param("a"), ctx.QuerySlice("a"), ctx.QueryInt("n", 7), ctx.QueryInt("bad", 7),
+			ctx.QueryBool("debug", false), ctx.QueryBool("missing", true), ctx.Params()["a"]}
+		model, path = queryModel{}, pathModel{}
+		bindErr = ctx.BindQuery(&model)
+		pathErr = ctx.BindPath(&path)
+		ctx.Status(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/query/items/9?debug&a=1&a=2,3&n=5&bad=x&size=20", nil))
+	if rec.Code != http.StatusOK || matchURI != "/query/items/9" {
+		t.Fatalf("code = %d, path = %q", rec.Code, matchURI)
+	}
+	want := []interface{}{"1", []string{"1", "2", "3"}, 5, 7, true, true, "1"}
+	if !reflect.DeepEqual(getters, want) {
+		t.Errorf("getters = %v", getters)
+	}
+	if nil != bindErr || model.Page != 1 || nil == model.Size || *model.Size != 20 || !reflect.DeepEqual(model.Tags, []string{"a", "b"}) ||
+		model.Timeout != time.Second || !model.Debug {
+		t.Errorf("bind query = %+v, %v", model, bindErr)
+	}
+	if nil != pathErr || path.ID != 9 || path.Kind != "all" {
+		t.Errorf("bind path = %+v, %v", path, pathErr)
+	}
+
+	rec = httptest.NewRecorder()
+	hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/query/items/0?page=x&tag=c&tag=d", nil))
+	if be, ok := bindErr.(*tune.BindError); !ok || be.Field != "Page" || be.Key != "page" || be.Value != "x" {
+		t.Errorf("bind error = %v", bindErr)
+	}
+	if _, ok := pathErr.(tune.ValidationErrors); !ok {
+		t.Errorf("path validate = %v", pathErr)
+	}
+
+	rec = httptest.NewRecorder()
+	hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/query/items/1?tag=c&tag=d,e&timeout=2m&name=abcd&ids=1,2&ids=3", nil))
+	if !reflect.DeepEqual(model.Tags, []string{"c", "d", "e"}) || !reflect.DeepEqual(model.IDs, []int{1, 2, 3}) || model.Timeout != 2*time.Minute {
+		t.Errorf("bind slice = %+v", model)
+	}
+	if _, ok := bindErr.(tune.ValidationErrors); !ok {
+		t.Errorf("query validate = %v", bindErr)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"github.com/aberic/gnomon"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsDefaultMethods 默认允许的跨域请求方法
+var corsDefaultMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// CORSConfig 跨域资源共享策略
+type CORSConfig struct {
+	AllowOrigins     []string                 // 允许的来源，支持“*”及“https://*.example.com”等通配
+	AllowOriginFunc  func(origin string) bool // 自定义来源校验方法，AllowOrigins 未匹配时调用
+	AllowMethods     []string                 // 允许的请求方法，为空时允许 GET、HEAD、POST、PUT、PATCH 及 DELETE
+	AllowHeaders     []string                 // 允许的请求头，为空时允许预检请求中声明的全部请求头
+	ExposeHeaders    []string                 // 允许浏览器读取的响应头
+	AllowCredentials bool                     // 是否允许携带cookie等凭证，允许时 AllowOrigins 不能包含“*”，须列出来源或通过 AllowOriginFunc 校验
+	MaxAge           time.Duration            // 预检请求结果的缓存时间，0表示不设置
+}
+
+// allowOrigin 来源是否被允许
+func (cc *CORSConfig) allowOrigin(origin string) bool {
+	for _, allow := range cc.AllowOrigins {
+		if allow == "*" || strings.EqualFold(allow, origin) {
+			return true
+		}
+		if index := strings.Index(allow, "*"); index >= 0 {
+			prefix, suffix := strings.ToLower(allow[:index]), strings.ToLower(allow[index+1:])
+			lower := strings.ToLower(origin)
+			if len(lower) > len(prefix)+len(suffix) && strings.HasPrefix(lower, prefix) && strings.HasSuffix(lower, suffix) &&
+				!strings.ContainsAny(lower[len(prefix):len(lower)-len(suffix)], "/:") {
+				return true
+			}
+		}
+	}
+	return nil != cc.AllowOriginFunc && cc.AllowOriginFunc(origin)
+}
+
+// anyOrigin 是否允许任意来源
+func (cc *CORSConfig) anyOrigin() bool {
+	for _, allow := range cc.AllowOrigins {
+		if allow == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// CORS 跨域资源共享过滤器/拦截器
+//
+// 预检请求由 GHttpRouter.Option 注册的路由或未注册时自动应答的 OPTIONS 路由触发，校验通过后应答 204 No Content，来源不被允许时应答 403 Forbidden
+//
+// 非预检请求的来源不被允许时不设置跨域响应头，由浏览器拒绝读取响应
+//
+// AllowCredentials 为true且 AllowOrigins 包含“*”时panic，避免任意来源均可携带凭证读取响应
+func CORS(config *CORSConfig) Filter {
+	if config.AllowCredentials && config.anyOrigin() {
+		panic("cors allow origins '*' can not be used with allow credentials")
+	}
+	allowMethods := config.AllowMethods
+	if len(allowMethods) == 0 {
+		allowMethods = corsDefaultMethods
+	}
+	methods := strings.Join(allowMethods, ", ")
+	headers := strings.Join(config.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(config.ExposeHeaders, ", ")
+	maxAge := strconv.FormatInt(int64(config.MaxAge/time.Second), 10)
+	anyOrigin := config.anyOrigin()
+	return func(ctx *Context) {
+		origin := ctx.HeaderGet("Origin")
+		if gnomon.StringIsEmpty(origin) {
+			return
+		}
+		header := ctx.writer.Header()
+		header.Add("Vary", "Origin")
+		preflight := ctx.request.Method == http.MethodOptions && gnomon.StringIsNotEmpty(ctx.HeaderGet("Access-Control-Request-Method"))
+		if !config.allowOrigin(origin) {
+			if preflight {
+				ctx.Abort()
+				ctx.Status(http.StatusForbidden)
+			}
+			return
+		}
+		if anyOrigin {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if config.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			if gnomon.StringIsNotEmpty(exposeHeaders) {
+				header.Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			return
+		}
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		header.Set("Access-Control-Allow-Methods", methods)
+		if gnomon.StringIsNotEmpty(headers) {
+			header.Set("Access-Control-Allow-Headers", headers)
+		} else if requestHeaders := ctx.HeaderGet("Access-Control-Request-Headers"); gnomon.StringIsNotEmpty(requestHeaders) {
+			header.Set("Access-Control-Allow-Headers", requestHeaders)
+		}
+		if config.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", maxAge)
+		}
+		ctx.Abort()
+		ctx.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	hs := NewHTTPServe(CORS(&CORSConfig{
+		AllowOrigins:     []string{"https://admin.example.com", "https://*.example.org"},
+		AllowHeaders:     []string{"Content-Type", "Authorization"},
+		ExposeHeaders:    []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}))
+	_ = hs.Group("/cors").Put("/items", func(ctx *Context) {
+		_ = ctx.ResponseText(http.StatusOK, "ok")
+	})
+	cases := []struct {
+		method    string
+		origin    string
+		preflight bool
+		status    int
+		allow     string
+	}{
+		{http.MethodOptions, "https://admin.example.com", true, http.StatusNoContent, "https://admin.example.com"},
+		{http.MethodOptions, "https://a.b.example.org", true, http.StatusNoContent, "https://a.b.example.org"},
+		{http.MethodOptions, "https://evil.com", true, http.StatusForbidden, ""},
+		{http.MethodOptions, "https://evil.com/.example.org", true, http.StatusForbidden, ""},
+		{http.MethodOptions, "", false, http.StatusNoContent, ""},
+		{http.MethodPut, "https://admin.example.com", false, http.StatusOK, "https://admin.example.com"},
+		{http.MethodPut, "https://evil.com", false, http.StatusOK, ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/cors/items", nil)
+		if c.origin != "" {
+			req.Header.Set("Origin", c.origin)
+		}
+		if c.preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+		}
+		rec := httptest.NewRecorder()
+		hs.ServeHTTP(rec, req)
+		header := rec.Header()
+		if rec.Code != c.status || header.Get("Access-Control-Allow-Origin") != c.allow {
+			t.Errorf("%s %s = %d %q", c.method, c.origin, rec.Code, header.Get("Access-Control-Allow-Origin"))
+			continue
+		}
+		if c.allow == "" {
+			continue
+		}
+		if header.Get("Access-Control-Allow-Credentials") != "true" {
+			t.Errorf("%s %s credentials = %v", c.method, c.origin, header)
+		}
+		if c.preflight && (header.Get("Access-Control-Max-Age") != "600" ||
+			header.Get("Access-Control-Allow-Headers") != "Content-Type, Authorization") {
+			t.Errorf("%s %s preflight = %v", c.method, c.origin, header)
+		}
+		if !c.preflight && header.Get("Access-Control-Expose-Headers") != "X-Request-ID" {
+			t.Errorf("%s %s expose = %v", c.method, c.origin, header)
+		}
+	}
+}
+
+func TestCORSAnyOriginCredentials(t *testing.T) {
+	hs := NewHTTPServe(CORS(&CORSConfig{AllowOrigins: []string{"*"}}))
+	_ = hs.Group("/cors").Get("/items", func(ctx *Context) {})
+	req := httptest.NewRequest(http.MethodGet, "/cors/items", nil)
+	req.Header.Set("Origin", "https://any.com")
+	rec := httptest.NewRecorder()
+	hs.ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatalf("any origin = %v", rec.Header())
+	}
+	defer func() {
+		if nil == recover() {
+			t.Error("expected panic for '*' with credentials")
+		}
+	}()
+	CORS(&CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true})
+}
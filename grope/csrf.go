@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"github.com/aberic/gnomon"
+	"github.com/aberic/gnomon/grope/tune"
+	"github.com/aberic/gnomon/log"
+	"net/http"
+	"strings"
+)
+
+const (
+	// csrfDefaultCookieName 默认保存令牌的cookie名称
+	csrfDefaultCookieName = "csrf_token"
+	// csrfDefaultHeaderName 默认提交令牌的请求头
+	csrfDefaultHeaderName = "X-CSRF-Token"
+	// csrfDefaultFormField 默认提交令牌的表单参数
+	csrfDefaultFormField = "csrf_token"
+	// csrfTokenLength 令牌随机字节数
+	csrfTokenLength = 32
+)
+
+// CSRFConfig 跨站请求伪造防护策略，采用双重提交cookie方式
+type CSRFConfig struct {
+	CookieName string        // 保存令牌的cookie名称，默认“csrf_token”
+	HeaderName string        // 提交令牌的请求头，默认“X-CSRF-Token”
+	FormField  string        // 提交令牌的表单参数，请求头为空时读取，默认“csrf_token”
+	Path       string        // cookie路径，默认“/”
+	Domain     string        // cookie域名
+	MaxAge     int           // cookie有效期（秒），0表示会话cookie
+	Secure     bool          // cookie是否仅通过https发送
+	SameSite   http.SameSite // cookie的SameSite属性，默认 http.SameSiteLaxMode
+	Failure    Handler       // 校验失败时的处理方法，默认应答 403 Forbidden
+}
+
+// CSRF 跨站请求伪造防护过滤器/拦截器
+//
+// 请求未携带令牌cookie时生成新的令牌并写入cookie，cookie不设置HttpOnly以便前端读取后通过请求头提交
+//
+// 除 GET、HEAD、OPTIONS 及 TRACE 外的请求须在请求头或表单参数中提交与cookie一致的令牌
+//
+// 本次请求的令牌可通过 Context.CSRFToken 获取，用于渲染表单
+func CSRF(config *CSRFConfig) Filter {
+	if nil == config {
+		config = &CSRFConfig{}
+	}
+	cookieName := stringDefault(config.CookieName, csrfDefaultCookieName)
+	headerName := stringDefault(config.HeaderName, csrfDefaultHeaderName)
+	formField := stringDefault(config.FormField, csrfDefaultFormField)
+	sameSite := config.SameSite
+	if sameSite == 0 {
+		sameSite = http.SameSiteLaxMode
+	}
+	return func(ctx *Context) {
+		token, err := ctx.Cookie(cookieName)
+		fresh := nil != err || len(token) != base64.RawURLEncoding.EncodedLen(csrfTokenLength)
+		if fresh {
+			if token, err = newCSRFToken(); nil != err {
+				ctx.LogError("csrf token", log.Err(err))
+				ctx.Abort()
+				ctx.Status(http.StatusInternalServerError)
+				return
+			}
+			previous := ctx.sameSite
+			ctx.SetSameSite(sameSite)
+			ctx.SetCookie(cookieName, token, config.MaxAge, config.Path, config.Domain, config.Secure, false)
+			ctx.SetSameSite(previous)
+		}
+		ctx.csrfToken = token
+		ctx.writer.Header().Add("Vary", "Cookie")
+		switch ctx.request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			return
+		}
+		submitted := ctx.HeaderGet(headerName)
+		if gnomon.StringIsEmpty(submitted) && csrfForm(ctx.ContentType()) {
+			submitted = ctx.request.PostFormValue(formField)
+		}
+		if !fresh && subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) == 1 {
+			return
+		}
+		ctx.Abort()
+		if nil != config.Failure {
+			config.Failure(ctx)
+			return
+		}
+		_ = ctx.ResponseJSON(http.StatusForbidden, &struct {
+			Message string `json:"message"`
+		}{
+			Message: "invalid csrf token",
+		})
+	}
+}
+
+// CSRFToken 获取本次请求的CSRF令牌，需使用 CSRF 过滤器
+func (c *Context) CSRFToken() string {
+	return c.csrfToken
+}
+
+// newCSRFToken 生成随机令牌
+func newCSRFToken() (string, error) {
+	bytes := make([]byte, csrfTokenLength)
+	if _, err := rand.Read(bytes); nil != err {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// csrfForm 是否为可读取表单参数的内容类型
+func csrfForm(contentType string) bool {
+	return strings.HasPrefix(contentType, tune.ContentTypePostForm) || strings.HasPrefix(contentType, tune.ContentTypeMultipartPostForm)
+}
+
+// stringDefault value为空时返回def
+func stringDefault(value, def string) string {
+	if gnomon.StringIsEmpty(value) {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	hs := NewHTTPServe(CSRF(nil))
+	route := hs.Group("/csrf")
+	_ = route.Get("/form", func(ctx *Context) {
+		_ = ctx.ResponseText(http.StatusOK, ctx.CSRFToken())
+	})
+	_ = route.Post("/submit", func(ctx *Context) {
+		_ = ctx.ResponseText(http.StatusOK, "ok")
+	})
+
+	rec := httptest.NewRecorder()
+	hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/csrf/form", nil))
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusOK || len(cookies) != 1 || cookies[0].Name != csrfDefaultCookieName ||
+		cookies[0].Value != rec.Body.String() || cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Fatalf("issue = %d %v %s", rec.Code, cookies, rec.Body.String())
+	}
+	token := cookies[0].Value
+
+	cases := []struct {
+		name   string
+		cookie string
+		header string
+		form   string
+		status int
+	}{
+		{"header", token, token, "", http.StatusOK},
+		{"form", token, "", token, http.StatusOK},
+		{"missing token", token, "", "", http.StatusForbidden},
+		{"mismatch", token, strings.Repeat("a", len(token)), "", http.StatusForbidden},
+		{"missing cookie", "", token, "", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		var req *http.Request
+		if c.form != "" {
+			req = httptest.NewRequest(http.MethodPost, "/csrf/submit", strings.NewReader(url.Values{csrfDefaultFormField: {c.form}}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req = httptest.NewRequest(http.MethodPost, "/csrf/submit", nil)
+		}
+		if c.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: csrfDefaultCookieName, Value: c.cookie})
+		}
+		req.Header.Set(csrfDefaultHeaderName, c.header)
+		rec = httptest.NewRecorder()
+		hs.ServeHTTP(rec, req)
+		if rec.Code != c.status {
+			t.Errorf("%s status = %d", c.name, rec.Code)
+		}
+	}
+}
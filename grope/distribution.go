@@ -18,10 +18,12 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"github.com/aberic/gnomon"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 )
@@ -76,49 +78,133 @@ func (c *Context) DistributionTLS(url string, tLSConfig *TLSConfig, fusing Fusin
 // transport 支持HTTP和HTTPS的传输配置
 func (c *Context) Distributions(addr string, transport *Transport, fusing Fusing) {
 	var (
-		client     *http.Client
-		req        *http.Request
-		resp       *http.Response
-		data       []byte
 		patternURL *url.URL
-		realURL    string
 		err        error
 	)
-	if patternURL, err = url.Parse(c.request.URL.String()); nil != err {
-		goto ERR
+	if patternURL, err = url.Parse(c.request.URL.String()); nil == err {
+		err = c.forward(gnomon.StringBuild(addr, patternURL.String()), transport)
 	}
-	realURL = gnomon.StringBuild(addr, patternURL.String())
+	fusing(err)
+}
+
+// hopHeaders 逐跳头部信息，仅对单次传输连接有效，转发时不应被代理传递
+//
+// http://www.w3.org/Protocols/rfc2616/rfc2616-sec13.html
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// forward 将当前请求转发至realURL，并将目标的响应状态、头部信息及内容以流的方式回写
+//
+// realURL 转发的真实地址，如“http://127.0.0.1:8080/demo/1/g?name=hello”
+//
+// transport 支持HTTP和HTTPS的传输配置
+func (c *Context) forward(realURL string, transport *Transport) error {
+	var (
+		client *http.Client
+		req    *http.Request
+		resp   *http.Response
+		body   io.Reader
+		err    error
+	)
 	if client, err = getTLSClient(transport); nil != err {
-		goto ERR
+		return err
 	}
-	if req, err = http.NewRequest(c.request.Method, realURL, c.request.Body); nil != err {
-		goto ERR
+	if c.request.ContentLength != 0 {
+		body = c.request.Body
 	}
-	// 设置Request头部信息
-	for k, v := range c.request.Header {
-		for _, vv := range v {
-			req.Header.Add(k, vv)
-		}
+	if req, err = http.NewRequestWithContext(c.request.Context(), c.request.Method, realURL, body); nil != err {
+		return err
 	}
-	resp, err = client.Do(req)
-	if err != nil {
-		goto ERR
+	req.ContentLength = c.request.ContentLength
+	// 设置Request头部信息
+	copyHeader(req.Header, c.request.Header)
+	removeHopHeaders(req.Header)
+	c.forwardedHeader(req.Header)
+	if resp, err = client.Do(req); nil != err {
+		return err
 	}
 	defer func() { _ = resp.Body.Close() }()
 	// 设置Response头部信息
-	for k, v := range resp.Header {
+	removeHopHeaders(resp.Header)
+	copyHeader(c.writer.Header(), resp.Header)
+	c.responded = true
+	c.Status(resp.StatusCode)
+	return copyResponse(c.writer, resp.Body, resp.ContentLength)
+}
+
+// forwardedHeader 追加“X-Forwarded-For”，并在未设置时补充“X-Forwarded-Proto”及“X-Forwarded-Host”
+func (c *Context) forwardedHeader(header http.Header) {
+	if clientIP, _, err := net.SplitHostPort(c.request.RemoteAddr); nil == err {
+		if prior, exist := header["X-Forwarded-For"]; exist {
+			clientIP = gnomon.StringBuild(strings.Join(prior, ", "), ", ", clientIP)
+		}
+		header.Set("X-Forwarded-For", clientIP)
+	}
+	if gnomon.StringIsEmpty(header.Get("X-Forwarded-Proto")) {
+		if nil == c.request.TLS {
+			header.Set("X-Forwarded-Proto", "http")
+		} else {
+			header.Set("X-Forwarded-Proto", "https")
+		}
+	}
+	if gnomon.StringIsEmpty(header.Get("X-Forwarded-Host")) {
+		header.Set("X-Forwarded-Host", c.request.Host)
+	}
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, v := range src {
 		for _, vv := range v {
-			c.writer.Header().Add(k, vv)
+			dst.Add(k, vv)
 		}
 	}
-	if data, err = ioutil.ReadAll(resp.Body); nil != err {
-		goto ERR
+}
+
+// removeHopHeaders 移除逐跳头部信息，包括“Connection”中声明的头部
+func removeHopHeaders(header http.Header) {
+	for _, connection := range header["Connection"] {
+		for _, key := range strings.Split(connection, ",") {
+			if key = strings.TrimSpace(key); gnomon.StringIsNotEmpty(key) {
+				header.Del(key)
+			}
+		}
 	}
-	if _, err = c.writer.Write(data); nil != err {
-		goto ERR
+	for _, key := range hopHeaders {
+		header.Del(key)
+	}
+}
+
+// copyResponse 以流的方式回写响应内容，未知长度的响应(如chunked)每次写入后立即刷新
+func copyResponse(w http.ResponseWriter, body io.Reader, contentLength int64) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok || contentLength != -1 {
+		_, err := io.Copy(w, body)
+		return err
+	}
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, errWrite := w.Write(buf[:n]); nil != errWrite {
+				return errWrite
+			}
+			flusher.Flush()
+		}
+		if err == io.EOF {
+			return nil
+		} else if nil != err {
+			return err
+		}
 	}
-ERR:
-	fusing(err)
 }
 
 func getTLSClient(transport *Transport) (*http.Client, error) {
@@ -147,7 +233,8 @@ func getTLSClientSync(tlsClientKey string, transport *Transport) (*http.Client,
 		return tlsClient, nil
 	}
 	var (
-		tlsClient = &http.Client{}
+		// 转发时不跟随重定向，将3xx响应及其Location原样返回客户端
+		tlsClient = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
 		err       error
 	)
 	if tlsClient.Transport, err = getTLSTransport(transport); nil != err {
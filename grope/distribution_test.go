@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDistribution(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Hop") != "" || r.Header.Get("Keep-Alive") != "" {
+			t.Errorf("hop-by-hop header forwarded: %v", r.Header)
+		}
+		if r.Header.Get("X-Forwarded-For") != "10.0.0.1, 192.0.2.1" {
+			t.Errorf("X-Forwarded-For = %s", r.Header.Get("X-Forwarded-For"))
+		}
+		if r.Header.Get("X-Forwarded-Proto") != "http" || r.Header.Get("X-Forwarded-Host") != "example.com" {
+			t.Errorf("X-Forwarded-Proto/Host = %v", r.Header)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Connection", "X-Secret")
+		w.Header().Set("X-Secret", "hidden")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(r.URL.RequestURI() + " " + string(body)))
+	}))
+	defer upstream.Close()
+
+	hs := NewHTTPServe()
+	route := hs.Group("/dist")
+	route.Post("/:id", func(ctx *Context) {
+		ctx.Distribution(upstream.URL, func(err error) {
+			if nil != err {
+				t.Error(err)
+			}
+		})
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/dist/1?a=b", strings.NewReader("payload"))
+	req.Header.Set("Connection", "X-Hop")
+	req.Header.Set("X-Hop", "1")
+	req.Header.Set("Keep-Alive", "timeout=5")
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	rec := httptest.NewRecorder()
+	hs.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if rec.Header().Get("X-Secret") != "" || rec.Header().Get("Connection") != "" {
+		t.Fatalf("hop-by-hop header returned: %v", rec.Header())
+	}
+	if body := rec.Body.String(); body != "/dist/1?a=b payload" {
+		t.Fatalf("body = %s", body)
+	}
+}
+
+func TestDistributionRedirect(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			_, _ = w.Write([]byte("followed"))
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}))
+	defer upstream.Close()
+
+	hs := NewHTTPServe()
+	route := hs.Group("/dist")
+	route.Get("/jump", func(ctx *Context) {
+		ctx.Distribution(upstream.URL, func(err error) {
+			if nil != err {
+				t.Error(err)
+			}
+		})
+	})
+
+	rec := httptest.NewRecorder()
+	hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dist/jump", nil))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/login" {
+		t.Fatalf("status = %d, location = %s", rec.Code, rec.Header().Get("Location"))
+	}
+}
@@ -15,13 +15,7 @@
 package grope
 
 import (
-	"crypto/rand"
-	"crypto/tls"
-	"crypto/x509"
 	"github.com/aberic/gnomon/log"
-	"io/ioutil"
-	"net/http"
-	"time"
 )
 
 // NewHTTPServe 新建一个Http服务
@@ -32,8 +26,10 @@ func NewHTTPServe(filters ...Filter) *GHttpServe {
 // ListenAndServe 启动监听
 //
 // Addr 期望监听的端口号，如“:8080”
+//
+// 需要配置超时或优雅关闭时使用 NewServer
 func ListenAndServe(Addr string, gs *GHttpServe) {
-	err := http.ListenAndServe(Addr, gs) //设置监听的端口
+	err := NewServer(Addr, gs).ListenAndServe() //设置监听的端口
 	if err != nil {
 		log.Panic("ListenAndServe", log.Err(err))
 	}
@@ -44,35 +40,24 @@ func ListenAndServe(Addr string, gs *GHttpServe) {
 // Addr 期望监听的端口号，如“:8080”
 //
 // 必须提供包含证书和与服务器匹配的私钥的文件。如果证书是由证书颁发机构签署的，则certFile应该是服务器证书、任何中间体和CA证书的连接。
+//
+// 提供caCertFilePaths时要求客户端提供经其验证的证书，证书文件变更后自动重新加载；insecureSkipVerify仅对客户端生效，保留以兼容旧版本
+//
+// 需要按SNI选择证书或配置客户端证书验证策略时使用 NewCertManager 及 NewServer
 func ListenAndServeTLS(gs *GHttpServe, Addr, certFilePath, keyFilePath string, insecureSkipVerify bool, caCertFilePaths ...string) {
 	//加载服务端证书，用于对方验证我方合法性
-	if cert, err := tls.LoadX509KeyPair(certFilePath, keyFilePath); err != nil {
+	cm, err := NewCertManager(certFilePath, keyFilePath)
+	if nil != err {
 		log.Panic("ListenAndServeTLS LoadX509KeyPair", log.Err(err))
-	} else {
-		tlsConfig := &tls.Config{
-			Certificates:       []tls.Certificate{cert},
-			ClientAuth:         tls.RequireAndVerifyClientCert,
-			InsecureSkipVerify: insecureSkipVerify,
-		}
-		if nil != caCertFilePaths && len(caCertFilePaths) > 0 {
-			clientCertPool := x509.NewCertPool()
-			//加载根证书，用于验证对方合法性
-			for _, caCertFilePath := range caCertFilePaths {
-				//这里读取的是根证书
-				if buf, err := ioutil.ReadFile(caCertFilePath); err != nil {
-					log.Panic("ListenAndServeTLS ReadFile", log.Err(err))
-				} else {
-					clientCertPool.AppendCertsFromPEM(buf)
-				}
-			}
-			tlsConfig.ClientCAs = clientCertPool
-		}
-		tlsConfig.Time = time.Now
-		tlsConfig.Rand = rand.Reader
-		if listener, err := tls.Listen("tcp", Addr, tlsConfig); nil != err {
-			log.Panic("Serve", log.Err(err))
-		} else {
-			log.Panic("Serve", log.Err(http.Serve(listener, gs)))
+	}
+	if len(caCertFilePaths) > 0 {
+		//加载根证书，用于验证对方合法性
+		if err = cm.SetClientAuth(ClientAuthRequire, caCertFilePaths...); nil != err {
+			log.Panic("ListenAndServeTLS ReadFile", log.Err(err))
 		}
 	}
+	server := NewServer(Addr, gs)
+	server.TLSConfig = cm.TLSConfig()
+	server.TLSConfig.InsecureSkipVerify = insecureSkipVerify
+	log.Panic("Serve", log.Err(server.ListenAndServeTLS("", "")))
 }
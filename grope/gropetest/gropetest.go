@@ -0,0 +1,361 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package gropetest 进程内的grope服务测试工具，无需监听端口
+//
+// 请求通过 http.Handler.ServeHTTP（通常为 grope.GHttpServe）直接处理并由 httptest.ResponseRecorder 记录应答，
+// 同一 Client 发起的请求共享cookie
+package gropetest
+
+import (
+	"bytes"
+	"github.com/aberic/gnomon"
+	"github.com/aberic/gnomon/grope/tune"
+	"github.com/golang/protobuf/proto"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// DefaultHost 请求默认使用的主机，cookie的域名按该主机匹配
+const DefaultHost = "example.com"
+
+// Client 测试客户端，保存默认请求头及cookie
+type Client struct {
+	handler http.Handler
+	header  http.Header
+	jar     http.CookieJar
+	base    *url.URL
+}
+
+// New 新建测试客户端
+//
+// handler 待测试的服务，如 grope.NewHTTPServe 返回的 GHttpServe
+func New(handler http.Handler) *Client {
+	jar, _ := cookiejar.New(nil)
+	return &Client{
+		handler: handler,
+		header:  http.Header{},
+		jar:     jar,
+		base:    &url.URL{Scheme: "http", Host: DefaultHost},
+	}
+}
+
+// SetHeader 设置该客户端全部请求的默认请求头
+func (c *Client) SetHeader(key, value string) *Client {
+	c.header.Set(key, value)
+	return c
+}
+
+// SetHost 设置请求的scheme及主机，如“https://api.example.com”，cookie按新主机匹配
+func (c *Client) SetHost(host string) *Client {
+	if base, err := url.Parse(host); nil == err && gnomon.StringIsNotEmpty(base.Host) {
+		c.base = &url.URL{Scheme: base.Scheme, Host: base.Host}
+	}
+	return c
+}
+
+// Jar 客户端保存的cookie
+func (c *Client) Jar() http.CookieJar {
+	return c.jar
+}
+
+// Cookies 客户端保存的当前主机的cookie
+func (c *Client) Cookies() []*http.Cookie {
+	return c.jar.Cookies(c.base)
+}
+
+// Get 新建GET请求
+func (c *Client) Get(path string) *Request {
+	return c.NewRequest(http.MethodGet, path)
+}
+
+// Head 新建HEAD请求
+func (c *Client) Head(path string) *Request {
+	return c.NewRequest(http.MethodHead, path)
+}
+
+// Post 新建POST请求
+func (c *Client) Post(path string) *Request {
+	return c.NewRequest(http.MethodPost, path)
+}
+
+// Put 新建PUT请求
+func (c *Client) Put(path string) *Request {
+	return c.NewRequest(http.MethodPut, path)
+}
+
+// Patch 新建PATCH请求
+func (c *Client) Patch(path string) *Request {
+	return c.NewRequest(http.MethodPatch, path)
+}
+
+// Delete 新建DELETE请求
+func (c *Client) Delete(path string) *Request {
+	return c.NewRequest(http.MethodDelete, path)
+}
+
+// Options 新建OPTIONS请求
+func (c *Client) Options(path string) *Request {
+	return c.NewRequest(http.MethodOptions, path)
+}
+
+// NewRequest 新建请求
+//
+// path 请求路径，可包含params，如“/user/1?name=hello”
+func (c *Client) NewRequest(method, path string) *Request {
+	return &Request{client: c, method: method, path: path, header: http.Header{}, query: url.Values{}}
+}
+
+// Request 测试请求，通过 Do 发起
+type Request struct {
+	client  *Client
+	method  string
+	path    string
+	header  http.Header
+	query   url.Values
+	cookies []*http.Cookie
+	body    []byte
+	err     error
+}
+
+// Header 设置请求头
+func (r *Request) Header(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+// Query 追加请求params
+func (r *Request) Query(key string, values ...string) *Request {
+	for _, value := range values {
+		r.query.Add(key, value)
+	}
+	return r
+}
+
+// Cookie 追加本次请求的cookie，不会保存至客户端
+func (r *Request) Cookie(cookie *http.Cookie) *Request {
+	r.cookies = append(r.cookies, cookie)
+	return r
+}
+
+// Body 设置请求内容及内容类型
+func (r *Request) Body(contentType string, body []byte) *Request {
+	r.header.Set("Content-Type", contentType)
+	r.body = body
+	return r
+}
+
+// Text 设置"text/plain"请求内容
+func (r *Request) Text(text string) *Request {
+	return r.Body(tune.ContentTypePlain, []byte(text))
+}
+
+// Form 设置"application/x-www-form-urlencoded"请求内容
+func (r *Request) Form(values url.Values) *Request {
+	return r.Body(tune.ContentTypePostForm, []byte(values.Encode()))
+}
+
+// JSON 设置"application/json"请求内容
+func (r *Request) JSON(obj interface{}) *Request {
+	return r.encode(tune.ContentTypeJSON, obj)
+}
+
+// XML 设置"application/xml"请求内容
+func (r *Request) XML(obj interface{}) *Request {
+	return r.encode(tune.ContentTypeXML, obj)
+}
+
+// Yaml 设置"application/x-yaml"请求内容
+func (r *Request) Yaml(obj interface{}) *Request {
+	return r.encode(tune.ContentTypeYaml, obj)
+}
+
+// MsgPack 设置"application/x-msgpack"请求内容
+func (r *Request) MsgPack(obj interface{}) *Request {
+	return r.encode(tune.ContentTypeMsgPack, obj)
+}
+
+// ProtoBuf 设置"application/x-protobuf"请求内容
+func (r *Request) ProtoBuf(pm proto.Message) *Request {
+	return r.encode(tune.ContentTypeProtoBuf, pm)
+}
+
+// encode 按内容类型序列化请求内容，失败时在 Do 中终止测试
+func (r *Request) encode(contentType string, obj interface{}) *Request {
+	body, err := tune.Encode(contentType, obj)
+	if nil != err {
+		r.err = err
+	}
+	return r.Body(contentType, body)
+}
+
+// Do 发起请求并返回记录的应答，应答中设置的cookie将保存至客户端
+func (r *Request) Do(t testing.TB) *Response {
+	t.Helper()
+	if nil != r.err {
+		t.Fatalf("%s %s: encode body: %v", r.method, r.path, r.err)
+	}
+	target, err := r.client.base.Parse(r.path)
+	if nil != err {
+		t.Fatalf("%s %s: parse path: %v", r.method, r.path, err)
+	}
+	if len(r.query) > 0 {
+		query := target.Query()
+		for key, values := range r.query {
+			query[key] = append(query[key], values...)
+		}
+		target.RawQuery = query.Encode()
+	}
+	var body io.Reader
+	if nil != r.body {
+		body = bytes.NewReader(r.body)
+	}
+	req := httptest.NewRequest(r.method, target.String(), body)
+	for key, values := range r.client.header {
+		req.Header[key] = values
+	}
+	for key, values := range r.header {
+		req.Header[key] = values
+	}
+	for _, cookie := range r.client.jar.Cookies(target) {
+		req.AddCookie(cookie)
+	}
+	for _, cookie := range r.cookies {
+		req.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	r.client.handler.ServeHTTP(recorder, req)
+	resp := &Response{ResponseRecorder: recorder, t: t, request: req}
+	if cookies := recorder.Result().Cookies(); len(cookies) > 0 {
+		r.client.jar.SetCookies(target, cookies)
+	}
+	return resp
+}
+
+// Response 记录的应答，断言失败时通过 testing.TB 报告错误
+type Response struct {
+	*httptest.ResponseRecorder
+	t       testing.TB
+	request *http.Request
+}
+
+// Request 发起的请求
+func (r *Response) Request() *http.Request {
+	return r.request
+}
+
+// Cookies 应答中设置的cookie
+func (r *Response) Cookies() []*http.Cookie {
+	return r.Result().Cookies()
+}
+
+// Decode 按应答的内容类型解析应答内容至obj，支持JSON、XML、Yaml、MsgPack及ProtoBuf
+func (r *Response) Decode(obj interface{}) error {
+	return tune.Decode(bytes.NewReader(r.Body.Bytes()), r.Header().Get("Content-Type"), obj)
+}
+
+// AssertStatus 断言状态码
+func (r *Response) AssertStatus(code int) *Response {
+	r.t.Helper()
+	if r.Code != code {
+		r.t.Errorf("%s status = %d, want %d, body = %q", r.describe(), r.Code, code, r.Body.String())
+	}
+	return r
+}
+
+// AssertHeader 断言响应头的值，value为空时断言该响应头不存在
+func (r *Response) AssertHeader(key, value string) *Response {
+	r.t.Helper()
+	if actual := r.Header().Get(key); actual != value {
+		r.t.Errorf("%s header %s = %q, want %q", r.describe(), key, actual, value)
+	}
+	return r
+}
+
+// AssertHeaderContains 断言响应头包含指定内容，多个值时任一值包含即可
+func (r *Response) AssertHeaderContains(key, sub string) *Response {
+	r.t.Helper()
+	values := r.Header()[http.CanonicalHeaderKey(key)]
+	for _, value := range values {
+		if strings.Contains(value, sub) {
+			return r
+		}
+	}
+	r.t.Errorf("%s header %s = %q, want containing %q", r.describe(), key, values, sub)
+	return r
+}
+
+// AssertBody 断言应答内容
+func (r *Response) AssertBody(body string) *Response {
+	r.t.Helper()
+	if actual := r.Body.String(); actual != body {
+		r.t.Errorf("%s body = %q, want %q", r.describe(), actual, body)
+	}
+	return r
+}
+
+// AssertBodyContains 断言应答内容包含指定内容
+func (r *Response) AssertBodyContains(sub string) *Response {
+	r.t.Helper()
+	if actual := r.Body.String(); !strings.Contains(actual, sub) {
+		r.t.Errorf("%s body = %q, want containing %q", r.describe(), actual, sub)
+	}
+	return r
+}
+
+// AssertDecode 解析应答内容至obj，失败时终止测试
+func (r *Response) AssertDecode(obj interface{}) *Response {
+	r.t.Helper()
+	if err := r.Decode(obj); nil != err {
+		r.t.Fatalf("%s decode %q body: %v", r.describe(), r.Header().Get("Content-Type"), err)
+	}
+	return r
+}
+
+// AssertModel 解析应答内容并断言与model相等，model为结构体或结构体指针，ProtoBuf按 proto.Equal 比较
+func (r *Response) AssertModel(model interface{}) *Response {
+	r.t.Helper()
+	modelType := reflect.TypeOf(model)
+	ptr := modelType.Kind() == reflect.Ptr
+	if ptr {
+		modelType = modelType.Elem()
+	}
+	actual := reflect.New(modelType)
+	r.AssertDecode(actual.Interface())
+	if pm, ok := actual.Interface().(proto.Message); ok && ptr {
+		if !proto.Equal(pm, model.(proto.Message)) {
+			r.t.Errorf("%s model = %v, want %v", r.describe(), pm, model)
+		}
+		return r
+	}
+	got := actual.Interface()
+	if !ptr {
+		got = actual.Elem().Interface()
+	}
+	if !reflect.DeepEqual(got, model) {
+		r.t.Errorf("%s model = %+v, want %+v", r.describe(), got, model)
+	}
+	return r
+}
+
+// describe 请求描述，用于错误信息
+func (r *Response) describe() string {
+	return gnomon.StringBuild(r.request.Method, " ", r.request.URL.RequestURI())
+}
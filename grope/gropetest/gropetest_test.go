@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gropetest
+
+import (
+	"github.com/aberic/gnomon/grope"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+type user struct {
+	Name string `json:"name" xml:"name" yaml:"name" msgpack:"name"`
+	Age  int    `json:"age" xml:"age" yaml:"age" msgpack:"age"`
+}
+
+func testServe() *grope.GHttpServe {
+	hs := grope.NewHTTPServe()
+	route := hs.Group("/test")
+	_ = route.Post("/echo", func(ctx *grope.Context) {
+		u := &user{}
+		if err := ctx.Bind(u); nil != err {
+			return
+		}
+		u.Age++
+		_ = ctx.Respond(http.StatusOK, u)
+	})
+	_ = route.Post("/proto", func(ctx *grope.Context) {
+		value := &wrappers.StringValue{}
+		if err := ctx.ReceiveProtoBuf(value); nil != err {
+			ctx.Status(http.StatusBadRequest)
+			return
+		}
+		value.Value += "!"
+		_ = ctx.ResponseProtoBuf(http.StatusOK, value)
+	})
+	_ = route.Get("/login", func(ctx *grope.Context) {
+		ctx.SetCookie("session", ctx.Param("name"), 0, "/", "", false, true)
+		ctx.Status(http.StatusNoContent)
+	})
+	_ = route.Get("/me", func(ctx *grope.Context) {
+		name, err := ctx.Cookie("session")
+		if nil != err {
+			ctx.Status(http.StatusUnauthorized)
+			return
+		}
+		ctx.HeaderSet("X-User", name)
+		_ = ctx.ResponseText(http.StatusOK, name+" "+ctx.Param("lang")+" "+ctx.HeaderGet("X-Trace"))
+	})
+	_ = route.Post("/form", func(ctx *grope.Context) {
+		_ = ctx.ResponseText(http.StatusOK, ctx.Request().PostFormValue("name"))
+	})
+	return hs
+}
+
+func TestClientCodec(t *testing.T) {
+	client := New(testServe())
+	want := &user{Name: "grope", Age: 2}
+	client.Post("/test/echo").JSON(&user{Name: "grope", Age: 1}).Do(t).
+		AssertStatus(http.StatusOK).AssertHeader("Content-Type", "application/json").AssertModel(want)
+	client.Post("/test/echo").Header("Accept", "application/xml").XML(&user{Name: "grope", Age: 1}).Do(t).
+		AssertStatus(http.StatusOK).AssertModel(*want)
+	client.Post("/test/echo").Header("Accept", "application/x-yaml").Yaml(&user{Name: "grope", Age: 1}).Do(t).
+		AssertStatus(http.StatusOK).AssertModel(want)
+	client.Post("/test/echo").Header("Accept", "application/x-msgpack").MsgPack(&user{Name: "grope", Age: 1}).Do(t).
+		AssertStatus(http.StatusOK).AssertHeaderContains("Vary", "Accept").AssertModel(want)
+	client.Post("/test/proto").ProtoBuf(&wrappers.StringValue{Value: "grope"}).Do(t).
+		AssertStatus(http.StatusOK).AssertModel(&wrappers.StringValue{Value: "grope!"})
+	client.Post("/test/echo").Text("plain").Do(t).AssertStatus(http.StatusUnsupportedMediaType)
+	client.Post("/test/form").Form(url.Values{"name": {"grope"}}).Do(t).AssertBody("grope")
+	client.Get("/test/none").Do(t).AssertStatus(http.StatusNotFound)
+}
+
+func TestClientCookie(t *testing.T) {
+	client := New(testServe()).SetHeader("X-Trace", "t1")
+	client.Get("/test/me").Do(t).AssertStatus(http.StatusUnauthorized)
+	resp := client.Get("/test/login?name=alice").Do(t).AssertStatus(http.StatusNoContent)
+	if cookies := resp.Cookies(); len(cookies) != 1 || cookies[0].Value != "alice" {
+		t.Fatalf("response cookies = %v", cookies)
+	}
+	if cookies := client.Cookies(); len(cookies) != 1 || cookies[0].Name != "session" {
+		t.Fatalf("jar cookies = %v", cookies)
+	}
+	client.Get("/test/me").Query("lang", "go").Do(t).
+		AssertStatus(http.StatusOK).AssertHeader("X-User", "alice").AssertBody("alice go t1")
+	client.Get("/test/me").Cookie(&http.Cookie{Name: "session", Value: "bob"}).Header("X-Trace", "t2").Do(t).
+		AssertBodyContains("t2")
+	client.SetHost("http://other.example.org")
+	client.Get("/test/me").Do(t).AssertStatus(http.StatusUnauthorized)
+}
+
+func TestResponseAssertFailure(t *testing.T) {
+	client := New(testServe())
+	fake := &fakeTB{}
+	client.Get("/test/none").Do(fake).AssertStatus(http.StatusOK).AssertHeader("X-None", "x").AssertBody("")
+	if fake.errors != 3 {
+		t.Errorf("errors = %d", fake.errors)
+	}
+}
+
+type fakeTB struct {
+	testing.TB
+	errors int
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.errors++
+}
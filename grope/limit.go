@@ -15,54 +15,261 @@
 package grope
 
 import (
+	"github.com/aberic/gnomon"
+	"github.com/dgrijalva/jwt-go"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	// LimitSlidingWindow 滑动窗口限流，任意 LimitMillisecond 时间段内最多允许 LimitCount 次请求
+	LimitSlidingWindow LimitStrategy = iota
+	// LimitTokenBucket 令牌桶限流，桶容量为 LimitCount，每 LimitMillisecond 匀速补充 LimitCount 个令牌
+	LimitTokenBucket
+)
+
+// limitSweepCount 每处理该数量的请求后清理一次长期未访问的限流对象
+const limitSweepCount = 1024
+
+// LimitStrategy 限流算法
+type LimitStrategy int
+
+// LimitKey 获取限流对象标识，相同标识的请求共享同一限流额度，返回空字符串表示全局共享
+type LimitKey func(ctx *Context) string
+
+// LimitKeyClientIP 以客户端IP作为限流对象标识
+func LimitKeyClientIP(ctx *Context) string {
+	return ctx.ClientIP()
+}
+
+// LimitKeyHeader 以请求头中指定key的值作为限流对象标识
+func LimitKeyHeader(key string) LimitKey {
+	return func(ctx *Context) string {
+		return ctx.HeaderGet(key)
+	}
+}
+
+// LimitKeyJWTSubject 以“Authorization: Bearer <token>”中经key验证的 jwt subject 作为限流对象标识，验证失败时以客户端IP作为标识
+func LimitKeyJWTSubject(key interface{}) LimitKey {
+	return func(ctx *Context) string {
+		authorization := ctx.HeaderGet("Authorization")
+		if strings.HasPrefix(authorization, "Bearer ") {
+			claims := &jwt.StandardClaims{}
+			if _, err := jwt.ParseWithClaims(authorization[7:], claims, func(*jwt.Token) (interface{}, error) {
+				return key, nil
+			}); nil == err && gnomon.StringIsNotEmpty(claims.Subject) {
+				return gnomon.StringBuild("sub:", claims.Subject)
+			}
+		}
+		return gnomon.StringBuild("ip:", ctx.ClientIP())
+	}
+}
+
 // Limit 限流策略
 type Limit struct {
 	LimitMillisecond         int64         // 请求限定的时间段（毫秒）
 	LimitCount               int           // 请求限定的时间段内允许的请求次数
 	LimitIntervalMillisecond int64         // 请求允许的最小间隔时间（毫秒），0表示不限
-	limitChan                chan struct{} // 限流通道
-	times                    []int64       // 请求时间数组
+	Strategy                 LimitStrategy // 限流算法，默认滑动窗口
+	Key                      LimitKey      // 限流对象标识，nil表示全局共享
+
+	buckets map[string]*limitBucket // 各限流对象当前状态
+	count   int                     // 已处理请求数，用于定期清理
+	once    sync.Once
+	lock    sync.Mutex
 }
 
-// new 新建限流策略
+// limitBucket 限流对象当前状态
+type limitBucket struct {
+	times  []time.Time // 滑动窗口内的请求时间，按时间先后排列
+	tokens float64     // 令牌桶中剩余令牌数
+	last   time.Time   // 最后一次放行请求的时间
+	update time.Time   // 令牌桶最后一次补充令牌的时间
+}
+
+// limitResult 限流判定结果
+type limitResult struct {
+	allowed    bool          // 是否放行
+	remaining  int           // 剩余可用次数
+	retryAfter time.Duration // 被拒绝时建议的重试间隔
+	reset      time.Time     // 额度完全恢复的时间
+}
+
+// init 初始化限流策略
 func (l *Limit) init() {
-	l.limitChan = make(chan struct{}, l.LimitCount)
-	l.times = []int64{}
-	for i := 0; i < l.LimitCount; i++ {
-		time.Sleep(10 * time.Nanosecond)
-		l.add(time.Now().UnixNano() / 1e6)
-	}
-}
-
-func (l *Limit) limit() {
-	for {
-		timeNow := time.Now().UnixNano() / 1e6
-		// 如果当前时间与时间数组第一时间差大于限定时间段，并且当前时间与时间数组最后时间差大于最小请求间隔，则放行新的请求
-		if timeNow-l.times[0] > l.LimitMillisecond && timeNow-l.times[len(l.times)-1] > l.LimitIntervalMillisecond {
-			<-l.limitChan // 取出一个元素，放行
-			l.resetTimes(time.Now().UnixNano() / 1e6)
-		} else {
-			time.Sleep(10 * time.Nanosecond)
+	l.once.Do(func() {
+		l.buckets = map[string]*limitBucket{}
+	})
+}
+
+// window 请求限定的时间段
+func (l *Limit) window() time.Duration {
+	return time.Duration(l.LimitMillisecond) * time.Millisecond
+}
+
+// interval 请求允许的最小间隔时间
+func (l *Limit) interval() time.Duration {
+	return time.Duration(l.LimitIntervalMillisecond) * time.Millisecond
+}
+
+// allow 判定当前请求是否放行，不会阻塞或启动额外的协程
+func (l *Limit) allow(ctx *Context) *limitResult {
+	l.init()
+	var key string
+	if nil != l.Key {
+		key = l.Key(ctx)
+	}
+	return l.take(key, time.Now())
+}
+
+// serve 对当前请求执行限流判定并设置“X-RateLimit-*”头部信息，被拒绝时应答 429 Too Many Requests 并返回false
+func (l *Limit) serve(ctx *Context) bool {
+	result := l.allow(ctx)
+	ctx.HeaderSet("X-RateLimit-Limit", strconv.Itoa(l.LimitCount))
+	ctx.HeaderSet("X-RateLimit-Remaining", strconv.Itoa(result.remaining))
+	ctx.HeaderSet("X-RateLimit-Reset", strconv.FormatInt(result.reset.Unix(), 10))
+	if result.allowed {
+		return true
+	}
+	retryAfter := int64(math.Ceil(result.retryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	ctx.HeaderSet("Retry-After", strconv.FormatInt(retryAfter, 10))
+	_ = ctx.ResponseJSON(http.StatusTooManyRequests, &struct {
+		Message string `json:"message"`
+	}{
+		Message: "request limit, please retry later",
+	})
+	return false
+}
+
+// take 为指定限流对象在now时刻申请一次请求额度
+func (l *Limit) take(key string, now time.Time) *limitResult {
+	defer l.lock.Unlock()
+	l.lock.Lock()
+	if l.count++; l.count >= limitSweepCount {
+		l.count = 0
+		l.sweep(now)
+	}
+	bucket, exist := l.buckets[key]
+	if !exist {
+		bucket = &limitBucket{tokens: float64(l.LimitCount), update: now}
+		l.buckets[key] = bucket
+	}
+	if l.LimitCount <= 0 {
+		return &limitResult{retryAfter: l.window(), reset: now.Add(l.window())}
+	}
+	if wait := bucket.last.Add(l.interval()).Sub(now); !bucket.last.IsZero() && wait > 0 {
+		result := l.state(bucket, now)
+		result.retryAfter = wait
+		return result
+	}
+	switch l.Strategy {
+	case LimitTokenBucket:
+		return l.takeToken(bucket, now)
+	default:
+		return l.takeWindow(bucket, now)
+	}
+}
+
+// takeWindow 滑动窗口算法
+func (l *Limit) takeWindow(bucket *limitBucket, now time.Time) *limitResult {
+	bucket.expire(now.Add(-l.window()))
+	if len(bucket.times) >= l.LimitCount {
+		return &limitResult{
+			retryAfter: bucket.times[0].Add(l.window()).Sub(now),
+			reset:      bucket.times[len(bucket.times)-1].Add(l.window()),
+		}
+	}
+	bucket.times = append(bucket.times, now)
+	bucket.last = now
+	return &limitResult{
+		allowed:   true,
+		remaining: l.LimitCount - len(bucket.times),
+		reset:     now.Add(l.window()),
+	}
+}
+
+// takeToken 令牌桶算法
+func (l *Limit) takeToken(bucket *limitBucket, now time.Time) *limitResult {
+	bucket.refill(now, l.rate(), float64(l.LimitCount))
+	if bucket.tokens < 1 {
+		return &limitResult{
+			retryAfter: l.tokenDuration(1 - bucket.tokens),
+			reset:      now.Add(l.tokenDuration(float64(l.LimitCount) - bucket.tokens)),
+		}
+	}
+	bucket.tokens--
+	bucket.last = now
+	return &limitResult{
+		allowed:   true,
+		remaining: int(bucket.tokens),
+		reset:     now.Add(l.tokenDuration(float64(l.LimitCount) - bucket.tokens)),
+	}
+}
+
+// rate 令牌桶每纳秒补充的令牌数
+func (l *Limit) rate() float64 {
+	if l.LimitMillisecond <= 0 {
+		return float64(l.LimitCount)
+	}
+	return float64(l.LimitCount) / float64(l.window())
+}
+
+// tokenDuration 补充tokens个令牌所需的时间，向上取整
+func (l *Limit) tokenDuration(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / l.rate()))
+}
+
+// state 不消耗额度的情况下获取限流对象当前状态
+func (l *Limit) state(bucket *limitBucket, now time.Time) *limitResult {
+	switch l.Strategy {
+	case LimitTokenBucket:
+		bucket.refill(now, l.rate(), float64(l.LimitCount))
+		return &limitResult{
+			remaining: int(bucket.tokens),
+			reset:     now.Add(l.tokenDuration(float64(l.LimitCount) - bucket.tokens)),
 		}
+	default:
+		bucket.expire(now.Add(-l.window()))
+		return &limitResult{remaining: l.LimitCount - len(bucket.times), reset: bucket.last.Add(l.window())}
 	}
 }
 
-// add 新增一个元素
-func (l *Limit) add(time int64) {
-	if len(l.times) < l.LimitCount {
-		l.times = append(l.times, time)
+// sweep 清理额度已完全恢复且超过最小间隔的限流对象，避免以客户端为标识时无限增长
+func (l *Limit) sweep(now time.Time) {
+	idle := l.window()
+	if l.interval() > idle {
+		idle = l.interval()
+	}
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.last) > idle {
+			delete(l.buckets, key)
+		}
 	}
 }
 
-// remove 移除第一个元素
-//func (l *Limit) remove() {
-//	l.times = l.times[1:]
-//}
+// expire 移除滑动窗口起始时间before之前的请求时间
+func (b *limitBucket) expire(before time.Time) {
+	index := 0
+	for index < len(b.times) && !b.times[index].After(before) {
+		index++
+	}
+	b.times = b.times[index:]
+}
 
-func (l *Limit) resetTimes(time int64) {
-	l.times = l.times[1:]
-	l.times = append(l.times, time)
+// refill 按速率rate补充令牌，最多补充至capacity
+func (b *limitBucket) refill(now time.Time, rate, capacity float64) {
+	if elapsed := now.Sub(b.update); elapsed > 0 {
+		b.tokens += float64(elapsed) * rate
+		if b.tokens > capacity {
+			b.tokens = capacity
+		}
+		b.update = now
+	}
 }
@@ -15,7 +15,10 @@
 package grope
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestLimit(t *testing.T) {
@@ -25,17 +28,66 @@ func TestLimit(t *testing.T) {
 		LimitIntervalMillisecond: 100,
 	}
 	l.init()
-	go l.limit()
-	loop(l, t)
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		if result := l.take("", now.Add(time.Duration(i)*100*time.Millisecond)); !result.allowed {
+			t.Fatalf("request %d rejected", i)
+		}
+	}
+	if result := l.take("", now.Add(450*time.Millisecond)); result.allowed || result.retryAfter != 50*time.Millisecond {
+		t.Fatalf("interval = %+v", result)
+	}
+	if result := l.take("", now.Add(500*time.Millisecond)); result.allowed || result.retryAfter != 500*time.Millisecond {
+		t.Fatalf("window = %+v", result)
+	}
+	if result := l.take("", now.Add(1001*time.Millisecond)); !result.allowed || result.remaining != 0 {
+		t.Fatalf("slide = %+v", result)
+	}
+	if result := l.take("other", now); !result.allowed || result.remaining != 4 {
+		t.Fatalf("other key = %+v", result)
+	}
 }
 
-func loop(limit *Limit, t *testing.T) {
-	i := 0
-	for i <= 20 {
-		t.Log("被堵住了 c len = ", len(limit.limitChan))
-		limit.limitChan <- struct{}{}
-		t.Log("被放行了 ", limit.times)
-		i++
-		//time.Sleep(100 * time.Millisecond)
+func TestLimitTokenBucket(t *testing.T) {
+	l := &Limit{LimitMillisecond: 1000, LimitCount: 2, Strategy: LimitTokenBucket}
+	l.init()
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if result := l.take("", now); !result.allowed {
+			t.Fatalf("request %d rejected", i)
+		}
+	}
+	if result := l.take("", now); result.allowed || result.retryAfter != 500*time.Millisecond {
+		t.Fatalf("empty bucket = %+v", result)
+	}
+	if result := l.take("", now.Add(500*time.Millisecond)); !result.allowed {
+		t.Fatalf("refill = %+v", result)
+	}
+}
+
+func TestLimitServe(t *testing.T) {
+	hs := NewHTTPServe()
+	_ = hs.Group("/limit").Gets("/ip", &Extend{Limit: &Limit{
+		LimitMillisecond: 60000,
+		LimitCount:       1,
+		Key:              LimitKeyClientIP,
+	}}, func(ctx *Context) { _ = ctx.ResponseText(http.StatusOK, "ok") })
+
+	request := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/limit/ip", nil)
+		req.Header.Set("X-Real-IP", ip)
+		rec := httptest.NewRecorder()
+		hs.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := request("10.0.0.1"); rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("first = %d %v", rec.Code, rec.Header())
+	}
+	rec := request("10.0.0.1")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "60" || rec.Header().Get("X-RateLimit-Limit") != "1" {
+		t.Fatalf("second = %d %v", rec.Code, rec.Header())
+	}
+	if rec := request("10.0.0.2"); rec.Code != http.StatusOK {
+		t.Fatalf("other client = %d", rec.Code)
 	}
 }
@@ -0,0 +1,277 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metricsContentType Prometheus文本格式的内容类型
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricsBuckets 请求耗时直方图的桶上限（秒），与Prometheus客户端默认值一致
+var metricsBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metrics 服务请求统计，按路由的项目路径及请求方法分组，未匹配路由的请求仅统计总数，避免标签基数随原始路径增长
+type metrics struct {
+	routes    sync.Map // 各路由的统计，key为 metricsKey，value为 *metricsRoute
+	unmatched uint64   // 未匹配路由的请求数
+}
+
+// metricsKey 路由统计标识
+type metricsKey struct {
+	method  string
+	pattern string
+}
+
+// metricsRoute 单个路由的请求统计
+type metricsRoute struct {
+	inFlight int64                   // 正在处理的请求数
+	rejected uint64                  // 被限流拒绝的请求数
+	statuses map[int]*metricsHistory // 各状态码的请求数及耗时分布
+	lock     sync.Mutex
+}
+
+// metricsHistory 请求数及耗时分布
+type metricsHistory struct {
+	count   uint64
+	sum     float64  // 耗时总和（秒）
+	buckets []uint64 // 与 metricsBuckets 对应的非累计计数
+}
+
+// metricsMethods 自动应答 405 及 OPTIONS 时作为标签的标准请求方法，其余请求方法统一为 metricsOtherMethod
+var metricsMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true,
+	http.MethodDelete: true, http.MethodConnect: true, http.MethodOptions: true, http.MethodTrace: true,
+}
+
+// metricsOtherMethod 非标准请求方法的标签值
+const metricsOtherMethod = "OTHER"
+
+// route 获取路由的统计，不存在时新建
+//
+// 自动应答 405 及 OPTIONS 的路由请求方法来自客户端，非标准请求方法统一统计为“OTHER”，避免标签基数随客户端输入增长
+func (m *metrics) route(rt *route) *metricsRoute {
+	method := rt.method
+	if rt.allowed && !metricsMethods[method] {
+		method = metricsOtherMethod
+	}
+	key := metricsKey{method: method, pattern: rt.pattern}
+	if mr, ok := m.routes.Load(key); ok {
+		return mr.(*metricsRoute)
+	}
+	mr, _ := m.routes.LoadOrStore(key, &metricsRoute{statuses: map[int]*metricsHistory{}})
+	return mr.(*metricsRoute)
+}
+
+// begin 开始处理请求
+func (mr *metricsRoute) begin() {
+	atomic.AddInt64(&mr.inFlight, 1)
+}
+
+// end 请求处理结束，记录状态码及耗时
+func (mr *metricsRoute) end(status int, elapsed time.Duration) {
+	atomic.AddInt64(&mr.inFlight, -1)
+	if status == 0 {
+		status = http.StatusOK
+	}
+	seconds := elapsed.Seconds()
+	mr.lock.Lock()
+	defer mr.lock.Unlock()
+	history, exist := mr.statuses[status]
+	if !exist {
+		history = &metricsHistory{buckets: make([]uint64, len(metricsBuckets))}
+		mr.statuses[status] = history
+	}
+	history.count++
+	history.sum += seconds
+	if index := sort.SearchFloat64s(metricsBuckets, seconds); index < len(metricsBuckets) {
+		history.buckets[index]++
+	}
+}
+
+// reject 请求被限流拒绝
+func (mr *metricsRoute) reject() {
+	atomic.AddUint64(&mr.rejected, 1)
+}
+
+// metricsSnapshot 单个路由统计的快照，用于输出
+type metricsSnapshot struct {
+	metricsKey
+	inFlight int64
+	rejected uint64
+	statuses []int
+	history  map[int]metricsHistory
+}
+
+// snapshot 获取全部路由统计的快照，按项目路径及请求方法排序
+func (m *metrics) snapshot() []*metricsSnapshot {
+	var snapshots []*metricsSnapshot
+	m.routes.Range(func(key, value interface{}) bool {
+		mr := value.(*metricsRoute)
+		snapshot := &metricsSnapshot{
+			metricsKey: key.(metricsKey),
+			inFlight:   atomic.LoadInt64(&mr.inFlight),
+			rejected:   atomic.LoadUint64(&mr.rejected),
+			history:    map[int]metricsHistory{},
+		}
+		mr.lock.Lock()
+		for status, history := range mr.statuses {
+			snapshot.statuses = append(snapshot.statuses, status)
+			snapshot.history[status] = metricsHistory{
+				count:   history.count,
+				sum:     history.sum,
+				buckets: append([]uint64{}, history.buckets...),
+			}
+		}
+		mr.lock.Unlock()
+		sort.Ints(snapshot.statuses)
+		snapshots = append(snapshots, snapshot)
+		return true
+	})
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].pattern == snapshots[j].pattern {
+			return snapshots[i].method < snapshots[j].method
+		}
+		return snapshots[i].pattern < snapshots[j].pattern
+	})
+	return snapshots
+}
+
+// Metrics 以Prometheus文本格式输出请求统计的处理方法，如“route.Get("/metrics", hs.Metrics())”
+//
+// 请求数及耗时直方图按路由的项目路径（如“/user/:id”而非实际请求路径）、请求方法及状态码统计，
+// 同时输出正在处理的请求数及被限流拒绝的请求数，未匹配任何路由的请求仅统计总数
+//
+// grope_http_requests_total 请求数
+//
+// grope_http_request_duration_seconds 请求耗时直方图
+//
+// grope_http_requests_in_flight 正在处理的请求数
+//
+// grope_http_limit_rejections_total 被限流拒绝的请求数
+//
+// grope_http_requests_unmatched_total 未匹配路由的请求数
+func (ghs *GHttpServe) Metrics() Handler {
+	return func(ctx *Context) {
+		ctx.HeaderSet("Content-Type", metricsContentType)
+		ctx.Status(http.StatusOK)
+		_ = ctx.response(ghs.metrics.exposition())
+	}
+}
+
+// exposition 生成Prometheus文本格式的统计内容
+func (m *metrics) exposition() []byte {
+	snapshots := m.snapshot()
+	buf := &bytes.Buffer{}
+
+	metricsHeader(buf, "grope_http_requests_total", "counter", "Total number of HTTP requests by route pattern, method and status.")
+	for _, snapshot := range snapshots {
+		for _, status := range snapshot.statuses {
+			metricsLine(buf, "grope_http_requests_total", snapshot.labels("code", strconv.Itoa(status)),
+				strconv.FormatUint(snapshot.history[status].count, 10))
+		}
+	}
+
+	metricsHeader(buf, "grope_http_request_duration_seconds", "histogram", "HTTP request latencies in seconds by route pattern, method and status.")
+	for _, snapshot := range snapshots {
+		for _, status := range snapshot.statuses {
+			history := snapshot.history[status]
+			code := strconv.Itoa(status)
+			var cumulative uint64
+			for index, bucket := range metricsBuckets {
+				cumulative += history.buckets[index]
+				metricsLine(buf, "grope_http_request_duration_seconds_bucket",
+					snapshot.labels("code", code, "le", strconv.FormatFloat(bucket, 'g', -1, 64)), strconv.FormatUint(cumulative, 10))
+			}
+			metricsLine(buf, "grope_http_request_duration_seconds_bucket", snapshot.labels("code", code, "le", "+Inf"),
+				strconv.FormatUint(history.count, 10))
+			metricsLine(buf, "grope_http_request_duration_seconds_sum", snapshot.labels("code", code),
+				strconv.FormatFloat(history.sum, 'g', -1, 64))
+			metricsLine(buf, "grope_http_request_duration_seconds_count", snapshot.labels("code", code),
+				strconv.FormatUint(history.count, 10))
+		}
+	}
+
+	metricsHeader(buf, "grope_http_requests_in_flight", "gauge", "Number of HTTP requests currently being served by route pattern and method.")
+	for _, snapshot := range snapshots {
+		metricsLine(buf, "grope_http_requests_in_flight", snapshot.labels(), strconv.FormatInt(snapshot.inFlight, 10))
+	}
+
+	metricsHeader(buf, "grope_http_limit_rejections_total", "counter", "Total number of HTTP requests rejected by Limit by route pattern and method.")
+	for _, snapshot := range snapshots {
+		metricsLine(buf, "grope_http_limit_rejections_total", snapshot.labels(), strconv.FormatUint(snapshot.rejected, 10))
+	}
+
+	metricsHeader(buf, "grope_http_requests_unmatched_total", "counter", "Total number of HTTP requests that matched no route.")
+	metricsLine(buf, "grope_http_requests_unmatched_total", "", strconv.FormatUint(atomic.LoadUint64(&m.unmatched), 10))
+	return buf.Bytes()
+}
+
+// labels 生成标签，包含请求方法、项目路径及pairs中按“名称, 值”排列的额外标签
+func (ms *metricsSnapshot) labels(pairs ...string) string {
+	var builder strings.Builder
+	builder.WriteString(`{method="`)
+	builder.WriteString(metricsEscape(ms.method))
+	builder.WriteString(`",pattern="`)
+	builder.WriteString(metricsEscape(ms.pattern))
+	builder.WriteString(`"`)
+	for index := 0; index+1 < len(pairs); index += 2 {
+		builder.WriteString(`,`)
+		builder.WriteString(pairs[index])
+		builder.WriteString(`="`)
+		builder.WriteString(metricsEscape(pairs[index+1]))
+		builder.WriteString(`"`)
+	}
+	builder.WriteString(`}`)
+	return builder.String()
+}
+
+// metricsHeader 输出统计项的说明及类型
+func metricsHeader(buf *bytes.Buffer, name, kind, help string) {
+	buf.WriteString("# HELP ")
+	buf.WriteString(name)
+	buf.WriteString(" ")
+	buf.WriteString(help)
+	buf.WriteString("\n# TYPE ")
+	buf.WriteString(name)
+	buf.WriteString(" ")
+	buf.WriteString(kind)
+	buf.WriteString("\n")
+}
+
+// metricsLine 输出一条统计数据
+func metricsLine(buf *bytes.Buffer, name, labels, value string) {
+	buf.WriteString(name)
+	buf.WriteString(labels)
+	buf.WriteString(" ")
+	buf.WriteString(value)
+	buf.WriteString("\n")
+}
+
+// metricsEscaper 标签值转义规则
+var metricsEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricsEscape 转义标签值中的反斜杠、双引号及换行
+func metricsEscape(value string) string {
+	return metricsEscaper.Replace(value)
+}
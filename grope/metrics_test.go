@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	hs := NewHTTPServe()
+	route := hs.Group("/metrics")
+	inFlight := make(chan string, 1)
+	_ = route.Get("/items/:id", func(ctx *Context) {
+		if ctx.Value("id") == "0" {
+			ctx.Status(http.StatusNotFound)
+			return
+		}
+		_ = ctx.ResponseText(http.StatusOK, ctx.Value("id"))
+	})
+	_ = route.Get("/slow", func(ctx *Context) {
+		rec := httptest.NewRecorder()
+		hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics/prometheus", nil))
+		inFlight <- rec.Body.String()
+	})
+	_ = route.Gets("/limit", &Extend{Limit: &Limit{LimitMillisecond: 60000, LimitCount: 1}}, func(ctx *Context) {})
+	_ = route.Get("/prometheus", hs.Metrics())
+
+	for _, path := range []string{"/metrics/items/1", "/metrics/items/2", "/metrics/items/0", "/metrics/limit", "/metrics/limit",
+		"/metrics/none", "/metrics/slow"} {
+		hs.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	if body := <-inFlight; !strings.Contains(body, `grope_http_requests_in_flight{method="GET",pattern="/metrics/slow"} 1`) {
+		t.Errorf("in flight = %s", body)
+	}
+
+	rec := httptest.NewRecorder()
+	hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics/prometheus", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("code = %d, content type = %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE grope_http_requests_total counter",
+		`grope_http_requests_total{method="GET",pattern="/metrics/items/:id",code="200"} 2`,
+		`grope_http_requests_total{method="GET",pattern="/metrics/items/:id",code="404"} 1`,
+		`grope_http_requests_total{method="GET",pattern="/metrics/limit",code="429"} 1`,
+		"# TYPE grope_http_request_duration_seconds histogram",
+		`grope_http_request_duration_seconds_bucket{method="GET",pattern="/metrics/items/:id",code="200",le="+Inf"} 2`,
+		`grope_http_request_duration_seconds_count{method="GET",pattern="/metrics/items/:id",code="200"} 2`,
+		`grope_http_requests_in_flight{method="GET",pattern="/metrics/slow"} 0`,
+		`grope_http_requests_in_flight{method="GET",pattern="/metrics/prometheus"} 1`,
+		`grope_http_limit_rejections_total{method="GET",pattern="/metrics/limit"} 1`,
+		`grope_http_limit_rejections_total{method="GET",pattern="/metrics/items/:id"} 0`,
+		"grope_http_requests_unmatched_total 1",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "/metrics/items/1") {
+		t.Errorf("raw path leaked into labels")
+	}
+}
+
+func TestMetricsUnknownMethods(t *testing.T) {
+	hs := NewHTTPServe()
+	route := hs.Group("/metrics")
+	_ = route.Get("/items/:id", func(ctx *Context) {})
+	_ = route.Get("/prometheus", hs.Metrics())
+	for _, method := range []string{"FOO1", "FOO2", "FOO3", "BAR", http.MethodPost, http.MethodOptions} {
+		hs.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/metrics/items/1", nil))
+	}
+	rec := httptest.NewRecorder()
+	hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics/prometheus", nil))
+	var series []string
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if strings.HasPrefix(line, "grope_http_requests_total{") {
+			series = append(series, line)
+		}
+	}
+	expect := []string{
+		`grope_http_requests_total{method="OPTIONS",pattern="/metrics/items/:id",code="204"} 1`,
+		`grope_http_requests_total{method="OTHER",pattern="/metrics/items/:id",code="405"} 4`,
+		`grope_http_requests_total{method="POST",pattern="/metrics/items/:id",code="405"} 1`,
+	}
+	if strings.Join(series, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("series = %s", strings.Join(series, "\n"))
+	}
+}
+
+func TestMetricsEscape(t *testing.T) {
+	if escaped := metricsEscape("a\\b\"c\nd"); escaped != `a\\b\"c\nd` {
+		t.Errorf("escaped = %s", escaped)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/aberic/gnomon/log"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// RequestIDHeader 请求ID请求头及响应头
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDMaxLength 沿用客户端请求ID的最大长度
+const requestIDMaxLength = 128
+
+// RequestID 请求ID过滤器/拦截器，沿用请求头中合法的“X-Request-ID”，否则生成新的请求ID，并写入响应头
+//
+// 请求ID可通过 Context.RequestID 获取，并由 Context.LogInfo 等方法附加至日志
+//
+// 建议作为首个过滤器/拦截器，如 NewHTTPServe(RequestID(), AccessLog(), Recovery())
+func RequestID() Filter {
+	return func(ctx *Context) {
+		requestID := ctx.HeaderGet(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		ctx.requestID = requestID
+		ctx.HeaderSet(RequestIDHeader, requestID)
+	}
+}
+
+// validRequestID 请求ID是否可沿用，仅允许字母、数字及“-_.:”，避免日志注入
+func validRequestID(requestID string) bool {
+	if len(requestID) == 0 || len(requestID) > requestIDMaxLength {
+		return false
+	}
+	for _, char := range requestID {
+		switch {
+		case char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z', char >= '0' && char <= '9':
+		case char == '-', char == '_', char == '.', char == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID 生成32位十六进制随机请求ID
+func newRequestID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); nil != err {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// AccessLog 访问日志过滤器/拦截器，请求处理完成后记录请求方法、路径、状态码、响应字节数、耗时及客户端IP
+//
+// 未匹配路由及被限流拒绝的请求不经过过滤器/拦截器，不会被记录
+func AccessLog() Filter {
+	return func(ctx *Context) {
+		start := time.Now()
+		ctx.Next()
+		ctx.LogInfo("grope access",
+			log.Field("method", ctx.request.Method),
+			log.Field("path", ctx.request.URL.Path),
+			log.Field("status", ctx.StatusCode()),
+			log.Field("bytes", ctx.Size()),
+			log.Field("latency", time.Since(start).String()),
+			log.Field("client_ip", ctx.ClientIP()))
+	}
+}
+
+// Recovery 异常恢复过滤器/拦截器，恢复后续过滤器/拦截器及请求处理方法中的panic，记录错误堆栈并应答 500 Internal Server Error
+//
+// 请求处理方法中的panic默认即会被恢复，该过滤器/拦截器用于同时恢复过滤器/拦截器中的panic
+func Recovery() Filter {
+	return func(ctx *Context) {
+		defer func() {
+			if err := recover(); nil != err {
+				ctx.recoverPanic(err)
+			}
+		}()
+		ctx.Next()
+	}
+}
+
+// recoverPanic 记录panic及错误堆栈，尚未写入响应时应答json格式的错误信息
+//
+// http.ErrAbortHandler 将继续向上抛出，由 net/http 中止响应
+func (c *Context) recoverPanic(err interface{}) {
+	if err == http.ErrAbortHandler {
+		panic(err)
+	}
+	c.LogError("grope panic",
+		log.Field("error", fmt.Sprint(err)),
+		log.Field("method", c.request.Method),
+		log.Field("path", c.request.URL.Path),
+		log.Field("stack", string(debug.Stack())))
+	c.Abort()
+	if c.Written() {
+		return
+	}
+	_ = c.ResponseJSON(http.StatusInternalServerError, &struct {
+		Message   string `json:"message"`
+		RequestID string `json:"request_id,omitempty"`
+	}{
+		Message:   http.StatusText(http.StatusInternalServerError),
+		RequestID: c.requestID,
+	})
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	var status int
+	var size int64
+	capture := func(ctx *Context) {
+		ctx.Next()
+		status, size = ctx.StatusCode(), ctx.Size()
+	}
+	hs := NewHTTPServe(RequestID(), AccessLog(), capture, Recovery())
+	route := hs.Group("/middleware")
+	_ = route.Post("/created", func(ctx *Context) {
+		_ = ctx.ResponseText(http.StatusCreated, "created")
+	})
+	_ = route.Get("/handler", func(ctx *Context) {
+		panic("handler")
+	})
+	_ = route.Get("/filter", func(ctx *Context) {
+		t.Error("handler should not run")
+	}, func(ctx *Context) {
+		panic("filter")
+	})
+
+	cases := []struct {
+		method    string
+		path      string
+		requestID string
+		status    int
+		size      int64
+	}{
+		{http.MethodPost, "/middleware/created", "trace-1", http.StatusCreated, 7},
+		{http.MethodGet, "/middleware/handler", "bad id\n", http.StatusInternalServerError, -1},
+		{http.MethodGet, "/middleware/filter", "", http.StatusInternalServerError, -1},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		req.Header.Set(RequestIDHeader, c.requestID)
+		rec := httptest.NewRecorder()
+		hs.ServeHTTP(rec, req)
+		requestID := rec.Header().Get(RequestIDHeader)
+		if rec.Code != c.status || status != c.status {
+			t.Errorf("%s status = %d, captured %d", c.path, rec.Code, status)
+		}
+		if c.size >= 0 && size != c.size {
+			t.Errorf("%s size = %d", c.path, size)
+		}
+		if validRequestID(c.requestID) && requestID != c.requestID {
+			t.Errorf("%s request id = %s", c.path, requestID)
+		} else if !validRequestID(c.requestID) && len(requestID) != 32 {
+			t.Errorf("%s generated request id = %s", c.path, requestID)
+		}
+		if c.status == http.StatusInternalServerError {
+			body := map[string]string{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); nil != err || body["request_id"] != requestID {
+				t.Errorf("%s body = %s", c.path, rec.Body.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"errors"
+	"github.com/aberic/gnomon"
+	"github.com/aberic/gnomon/grope/tune"
+	"github.com/golang/protobuf/proto"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedMediaType unsupported media type
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	// ErrNotAcceptable not acceptable
+	ErrNotAcceptable = errors.New("not acceptable")
+)
+
+// negotiateOffers 支持协商的内容类型，按服务端优先级排列
+var negotiateOffers = []string{
+	tune.ContentTypeJSON,
+	tune.ContentTypeXML,
+	tune.ContentTypeYaml,
+	tune.ContentTypeMsgPack,
+	tune.ContentTypeProtoBuf,
+}
+
+// SetDefaultContentType 设置请求未指定“Content-Type”或“Accept”时 Context.Bind 及 Context.Respond 使用的内容类型，默认“application/json”
+func (ghs *GHttpServe) SetDefaultContentType(contentType string) {
+	ghs.contentType = tune.MediaType(contentType)
+}
+
+// defaultContentType 请求未指定内容类型时使用的内容类型
+func (c *Context) defaultContentType() string {
+	if gnomon.StringIsEmpty(c.contentType) {
+		return tune.ContentTypeJSON
+	}
+	return c.contentType
+}
+
+// Bind 根据请求头“Content-Type”选择解析方式并将请求内容解析至model
+//
+// 支持json、xml、yaml、msgpack及protobuf，未指定“Content-Type”时使用默认内容类型
+//
+// 解析后按“validate”标签校验，校验不通过时返回 tune.ValidationErrors
+//
+// 内容类型不受支持时应答 415 Unsupported Media Type 并返回 ErrUnsupportedMediaType
+func (c *Context) Bind(model interface{}) error {
+	contentType := c.ContentType()
+	if gnomon.StringIsEmpty(contentType) {
+		contentType = c.defaultContentType()
+	}
+	if err := tune.Decode(c.request.Body, contentType, model); nil != err {
+		if err == tune.ErrContentType {
+			_ = c.ResponseText(http.StatusUnsupportedMediaType, http.StatusText(http.StatusUnsupportedMediaType))
+			return ErrUnsupportedMediaType
+		}
+		return err
+	}
+	return tune.ValidateStruct(model)
+}
+
+// Respond 根据请求头“Accept”中的内容类型及权重选择编码方式并应答model
+//
+// “Accept”为空或通配时使用默认内容类型，model未实现 proto.Message 时不参与protobuf协商
+//
+// 无可接受的内容类型时应答 406 Not Acceptable 并返回 ErrNotAcceptable
+//
+// statusCode eg:http.StatusOK
+func (c *Context) Respond(statusCode int, model interface{}) error {
+	offers := negotiateOffers
+	pm, isProto := model.(proto.Message)
+	if !isProto {
+		offers = offers[:len(offers)-1]
+	}
+	defaultOffer := c.defaultContentType()
+	if defaultOffer == tune.ContentTypeProtoBuf && !isProto {
+		defaultOffer = tune.ContentTypeJSON
+	}
+	c.writer.Header().Add("Vary", "Accept")
+	switch tune.Negotiate(c.requestHeader("Accept"), offers, defaultOffer) {
+	case tune.ContentTypeJSON:
+		return c.ResponseJSON(statusCode, model)
+	case tune.ContentTypeXML:
+		return c.ResponseXML(statusCode, model)
+	case tune.ContentTypeYaml:
+		return c.ResponseYaml(statusCode, model)
+	case tune.ContentTypeMsgPack:
+		return c.ResponseMsgPack(statusCode, model)
+	case tune.ContentTypeProtoBuf:
+		return c.ResponseProtoBuf(statusCode, pm)
+	}
+	_ = c.ResponseText(http.StatusNotAcceptable, gnomon.StringBuild("acceptable content types: ", strings.Join(offers, ", ")))
+	return ErrNotAcceptable
+}
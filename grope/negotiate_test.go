@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"github.com/aberic/gnomon/grope/tune"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type negotiateModel struct {
+	Name string `json:"name" xml:"name" yaml:"name"`
+}
+
+func TestContextBind(t *testing.T) {
+	hs := NewHTTPServe()
+	_ = hs.Group("/negotiate").Post("/bind", func(ctx *Context) {
+		model := &negotiateModel{}
+		if err := ctx.Bind(model); nil != err {
+			return
+		}
+		_ = ctx.ResponseText(http.StatusOK, model.Name)
+	})
+	cases := []struct {
+		contentType string
+		body        string
+		status      int
+		name        string
+	}{
+		{"application/json; charset=utf-8", `{"name":"json"}`, http.StatusOK, "json"},
+		{"", `{"name":"default"}`, http.StatusOK, "default"},
+		{"text/xml", `<negotiateModel><name>xml</name></negotiateModel>`, http.StatusOK, "xml"},
+		{"application/x-yaml", "name: yaml", http.StatusOK, "yaml"},
+		{"text/csv", "name\ncsv", http.StatusUnsupportedMediaType, ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/negotiate/bind", strings.NewReader(c.body))
+		if c.contentType != "" {
+			req.Header.Set("Content-Type", c.contentType)
+		}
+		rec := httptest.NewRecorder()
+		hs.ServeHTTP(rec, req)
+		if rec.Code != c.status {
+			t.Errorf("%q status = %d", c.contentType, rec.Code)
+		} else if c.status == http.StatusOK && rec.Body.String() != c.name {
+			t.Errorf("%q name = %s", c.contentType, rec.Body.String())
+		}
+	}
+}
+
+func TestContextRespond(t *testing.T) {
+	hs := NewHTTPServe()
+	hs.SetDefaultContentType(tune.ContentTypeYaml)
+	_ = hs.Group("/negotiate").Get("/respond", func(ctx *Context) {
+		_ = ctx.Respond(http.StatusCreated, &negotiateModel{Name: "aberic"})
+	})
+	cases := []struct {
+		accept      string
+		status      int
+		contentType string
+	}{
+		{"", http.StatusCreated, tune.ContentTypeYaml},
+		{"*/*", http.StatusCreated, tune.ContentTypeYaml},
+		{"application/json", http.StatusCreated, tune.ContentTypeJSON},
+		{"text/html, application/xml;q=0.9, application/json;q=0.8", http.StatusCreated, tune.ContentTypeXML},
+		{"application/*;q=0.5, application/x-msgpack", http.StatusCreated, tune.ContentTypeMsgPack},
+		{"application/json;q=0, */*;q=0.1", http.StatusCreated, tune.ContentTypeYaml},
+		{"*/*, application/x-yaml;q=0", http.StatusCreated, tune.ContentTypeJSON},
+		{"application/x-protobuf", http.StatusNotAcceptable, tune.ContentTypePlain},
+		{"text/html", http.StatusNotAcceptable, tune.ContentTypePlain},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/negotiate/respond", nil)
+		if c.accept != "" {
+			req.Header.Set("Accept", c.accept)
+		}
+		rec := httptest.NewRecorder()
+		hs.ServeHTTP(rec, req)
+		if rec.Code != c.status || rec.Header().Get("Content-Type") != c.contentType {
+			t.Errorf("%q = %d %s", c.accept, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if rec.Header().Get("Vary") != "Accept" {
+			t.Errorf("%q vary = %s", c.accept, rec.Header().Get("Vary"))
+		}
+	}
+}
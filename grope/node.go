@@ -17,8 +17,11 @@ package grope
 import (
 	"fmt"
 	"github.com/aberic/gnomon"
-	"github.com/aberic/gnomon/log"
 	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -27,39 +30,107 @@ func newNode(filters ...Filter) *node {
 	return &node{
 		root:      true,
 		filters:   filters,
+		routes:    map[string]*route{},
 		nextNodes: []*node{},
 	}
 }
 
-func nextNode(n *node, patternPiece string) *node {
-	return &node{
+func nextNode(n *node, patternPiece string) (*node, error) {
+	nd := &node{
 		root:         false,
 		patternPiece: patternPiece,
-		filters:      n.filters,
+		routes:       map[string]*route{},
 		preNode:      n,
 		nextNodes:    []*node{},
 	}
+	if strings.HasPrefix(patternPiece, "?<") {
+		matcher, err := compileConstraint(patternPiece[2 : len(patternPiece)-1])
+		if nil != err {
+			return nil, err
+		}
+		nd.matcher = matcher
+	}
+	return nd, nil
 }
 
-type node struct {
-	root         bool     // 是否根结点
-	pattern      string   // /a/b/:c/d/:e/:f/g
-	patternPiece string   // a || ?
-	method       string   // eg:http.MethodGet
-	handler      Handler  // 待实现接收请求方法
-	filters      []Filter // 过滤器/拦截器数组
-	extend       *Extend  // 扩展方案，如限流等
-	error        *struct {
-		Message string `json:"message"`
+// constraints 内置的泛型参数约束，如“:id<int>”
+var constraints = map[string]string{
+	"int":   `-?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"alpha": `[a-zA-Z]+`,
+	"alnum": `[a-zA-Z0-9]+`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
+// compileConstraint 编译泛型参数约束，内置约束之外的内容视为正则表达式，并要求完整匹配
+func compileConstraint(constraint string) (*regexp.Regexp, error) {
+	if expr, exist := constraints[constraint]; exist {
+		constraint = expr
+	}
+	return regexp.Compile(gnomon.StringBuild("^(?:", constraint, ")$"))
+}
+
+// parseParam 解析泛型参数，如“:id<int>”解析为“id”及“int”，“*filepath”解析为“filepath”
+func parseParam(patternPiece string) (name, constraint string) {
+	name = patternPiece[1:]
+	if index := strings.Index(name, "<"); index > 0 && strings.HasSuffix(name, ">") {
+		return name[:index], name[index+1 : len(name)-1]
+	}
+	return name, ""
+}
+
+// piece 将项目路径片段转换为结点片段
+//
+// “:id”转换为“?”，“:id<int>”转换为“?<int>”，“*filepath”转换为“*”，其余保持不变
+func piece(patternPiece string) string {
+	if len(patternPiece) == 0 {
+		return patternPiece
+	}
+	switch patternPiece[0] {
+	case ':':
+		if _, constraint := parseParam(patternPiece); gnomon.StringIsNotEmpty(constraint) {
+			return gnomon.StringBuild("?<", constraint, ">")
+		}
+		return "?"
+	case '*':
+		return "*"
 	}
-	preNode   *node
-	nextNodes []*node
+	return patternPiece
+}
 
-	proxy *Proxy // 请求代理结构
+type node struct {
+	root         bool              // 是否根结点
+	patternPiece string            // a || ? || ?<int> || *
+	matcher      *regexp.Regexp    // 带约束的泛型参数匹配规则，如“:id<int>”
+	filters      []Filter          // 当前结点的过滤器/拦截器数组，请求时与上级结点的过滤器/拦截器按由根至叶的顺序组合
+	extend       *Extend           // 当前结点的扩展方案，请求时与上级结点的扩展方案组合
+	routes       map[string]*route // 当前结点各请求方法对应的路由，key为请求方法，eg:http.MethodGet
+	preNode      *node
+	nextNodes    []*node
 
 	lockNode sync.Mutex
 }
 
+// route 结点中指定请求方法的路由
+type route struct {
+	pattern string        // /a/b/:c/d/:e/:f/g
+	method  string        // eg:http.MethodGet
+	handler Handler       // 待实现接收请求方法
+	filters []Filter      // 路由自身的过滤器/拦截器数组，在所属结点继承的过滤器/拦截器之后执行
+	extend  *Extend       // 路由自身的扩展方案，如限流等，优先于所属结点继承的扩展方案
+	leaf    *node         // 路由所属的叶子结点
+	proxy   *Proxy        // 请求代理结构
+	params  []*routeParam // 项目路径中的泛型参数
+	allowed bool          // 是否为 allowRoute 生成的路由，其请求方法来自客户端
+}
+
+// routeParam 项目路径中的泛型参数
+type routeParam struct {
+	index    int    // 在项目路径片段中的下标
+	name     string // 参数名称，如“:id<int>”中的“id”
+	catchAll bool   // 是否为“*filepath”形式的通配参数，匹配剩余全部路径
+}
+
 // add
 //
 // pattern /a/b/:c/d/:e/:f/g
@@ -67,15 +138,15 @@ type node struct {
 // method eg:http.MethodGet
 //
 // proxyHost 代理转发地址
-func (n *node) add(pattern, method string, extend *Extend, handler Handler, proxy *Proxy, filters ...Filter) {
+func (n *node) add(pattern, method string, extend *Extend, handler Handler, proxy *Proxy, filters ...Filter) error {
 	if !n.root {
 		panic("only root can add node")
 	}
 	if pattern[0] != '/' {
 		panic("path must begin with '/'")
 	}
-	patternSplitArr := strings.Split(pattern, "/")[1:]                                 // [a, b, :c, d, :e, :f, g]
-	n.addFunc(pattern, method, patternSplitArr, 0, extend, handler, proxy, filters...) // 默认splitArr从0开始解析
+	patternSplitArr := strings.Split(pattern, "/")[1:]                                        // [a, b, :c, d, :e, :f, g]
+	return n.addFunc(pattern, method, patternSplitArr, 0, extend, handler, proxy, filters...) // 默认splitArr从0开始解析
 }
 
 // addSplitArr
@@ -87,12 +158,11 @@ func (n *node) add(pattern, method string, extend *Extend, handler Handler, prox
 // patternSplitArr [a, b, ?, d, ?, ?, g]
 //
 // index 1
-func (n *node) addSplitArr(pattern, method string, patternSplitArr []string, index int, extend *Extend, handler Handler, proxy *Proxy, filters ...Filter) {
+func (n *node) addSplitArr(pattern, method string, patternSplitArr []string, index int, extend *Extend, handler Handler, proxy *Proxy, filters ...Filter) error {
 	if len(patternSplitArr) == index { // splitArr长度与index相同则表明当前结点是叶子结点
-		n.fill(pattern, method, extend, handler, proxy, filters...)
-		return
+		return n.fill(pattern, method, extend, handler, proxy, filters...)
 	}
-	n.addFunc(pattern, method, patternSplitArr, index, extend, handler, proxy, filters...)
+	return n.addFunc(pattern, method, patternSplitArr, index, extend, handler, proxy, filters...)
 }
 
 // addFunc
@@ -104,57 +174,76 @@ func (n *node) addSplitArr(pattern, method string, patternSplitArr []string, ind
 // patternSplitArr [a, b, ?, d, ?, ?, g]
 //
 // index 1
-func (n *node) addFunc(pattern, method string, patternSplitArr []string, index int, extend *Extend, handler Handler, proxy *Proxy, filters ...Filter) {
-	var patternPiece string
-	if patternPiece = patternSplitArr[index]; patternPiece[0] == ':' {
-		patternPiece = "?"
+func (n *node) addFunc(pattern, method string, patternSplitArr []string, index int, extend *Extend, handler Handler, proxy *Proxy, filters ...Filter) error {
+	patternPiece := piece(patternSplitArr[index])
+	if patternPiece == "*" && index != len(patternSplitArr)-1 {
+		return fmt.Errorf("route %s catch-all must be the last segment", pattern)
 	}
 	index++
+	n.lockNode.Lock()
 	for _, nd := range n.nextNodes {
 		if nd.patternPiece == patternPiece {
-			if gnomon.StringIsNotEmpty(nd.method) && nd.method != method {
-				break
-			} else {
-				nd.addSplitArr(pattern, method, patternSplitArr, index, extend, handler, proxy, filters...)
-			}
-			return
+			n.lockNode.Unlock()
+			return nd.addSplitArr(pattern, method, patternSplitArr, index, extend, handler, proxy, filters...)
 		}
 	}
-	nextNode := nextNode(n, patternPiece)
-	n.lockNode.Lock()
+	nextNode, err := nextNode(n, patternPiece)
+	if nil != err {
+		n.lockNode.Unlock()
+		return err
+	}
 	n.nextNodes = append(n.nextNodes, nextNode)
 	n.lockNode.Unlock()
-	nextNode.addSplitArr(pattern, method, patternSplitArr, index, extend, handler, proxy, filters...)
+	return nextNode.addSplitArr(pattern, method, patternSplitArr, index, extend, handler, proxy, filters...)
 }
 
-// fill
-func (n *node) fill(pattern, method string, extend *Extend, handler Handler, proxy *Proxy, filters ...Filter) {
-	if gnomon.StringIsNotEmpty(n.method) {
-		return
+// fill 在叶子结点中设置指定请求方法的路由，method为空时表示为路由分组追加过滤器/拦截器及设置扩展方案
+//
+// 叶子结点中已存在该请求方法的路由时返回错误，如“/demo/:id”与“/demo/:name”
+func (n *node) fill(pattern, method string, extend *Extend, handler Handler, proxy *Proxy, filters ...Filter) error {
+	defer n.lockNode.Unlock()
+	n.lockNode.Lock()
+	if gnomon.StringIsEmpty(method) {
+		n.filters = append(append([]Filter{}, n.filters...), filters...)
+		if nil != extend {
+			n.extend = extend
+		}
+		return nil
 	}
-	n.filters = append(n.filters, filters...)
-	n.pattern = pattern
-	n.method = method
-	n.extend = extend
-	n.handler = handler
-	if nil != proxy && nil != proxy.Target {
-		n.proxy = proxy
+	if exist, ok := n.routes[method]; ok {
+		if exist.pattern == pattern {
+			return fmt.Errorf("route %s %s is already registered", method, pattern)
+		}
+		return fmt.Errorf("route %s %s conflicts with registered %s", method, pattern, exist.pattern)
+	}
+	r := &route{
+		pattern: pattern,
+		method:  method,
+		handler: handler,
+		filters: filters,
+		extend:  extend,
+		leaf:    n,
+		params:  parseParams(pattern),
 	}
-	if gnomon.StringIsNotEmpty(method) {
-		fmt.Printf("grope url %s %s \n", method, pattern)
+	if nil != proxy && nil != proxy.Target {
+		r.proxy = proxy
 	}
+	n.routes[method] = r
+	fmt.Printf("grope url %s %s \n", method, pattern)
 	if nil != extend && nil != extend.Limit {
-		n.extend.Limit.init()
-		go n.extend.Limit.limit()
+		r.extend.Limit.init()
 	}
+	return nil
 }
 
-// fetch
+// fetch 获取与请求路径及请求方法匹配的路由
+//
+// 请求路径匹配但请求方法不匹配时，返回用于自动应答 OPTIONS 或 405 Method Not Allowed 的路由；请求路径不匹配时返回nil
 //
 // pattern /a/b/:c/d/:e/:f/g
 //
 // method eg:http.MethodGet
-func (n *node) fetch(pattern, method string) *node {
+func (n *node) fetch(pattern, method string) *route {
 	if !n.root {
 		panic("only root can fetch node")
 	}
@@ -162,90 +251,250 @@ func (n *node) fetch(pattern, method string) *node {
 		panic("path must begin with '/'")
 	}
 	patternSplitArr := strings.Split(pattern, "/")[1:] // [a, b, :c, d, :e, :f, g]
-	nodal := n.fetchSplitArr(pattern, method, patternSplitArr, 0)
-	if nil != nodal && nil != nodal.extend && nil != nodal.extend.Limit {
-		if len(nodal.extend.Limit.limitChan) >= nodal.extend.Limit.LimitCount {
-			nodal.error = &struct {
-				Message string `json:"message"`
-			}{
-				Message: "request limit, please retry later",
-			}
-		} else {
-			nodal.extend.Limit.limitChan <- struct{}{}
-		}
+	leaves := n.search(patternSplitArr, 0, nil)        // 默认splitArr从0开始解析
+	if len(leaves) == 0 {
+		return nil
 	}
-	return nodal // 默认splitArr从0开始解析
+	r := matchRoute(leaves, method)
+	if nil == r && method == http.MethodHead { // 未设置 HEAD 路由时由 GET 路由处理
+		r = matchRoute(leaves, http.MethodGet)
+	}
+	if nil == r {
+		return allowRoute(leaves, method)
+	}
+	return r
 }
 
-// fetchFunc
+// search 查找与请求路径匹配且存在路由的所有叶子结点
 //
-// pattern /a/b/:c/d/:e/:f/g
+// 匹配优先级依次为静态结点、带约束的泛型结点、泛型结点及通配结点
 //
-// method eg:http.MethodGet
-//
-// patternSplitArr [a, b, ?, d, ?, ?, g]
+// patternSplitArr [a, b, c, d, e, f, g]
 //
 // index 1
-func (n *node) fetchSplitArr(pattern string, method string, patternSplitArr []string, index int) *node {
+func (n *node) search(patternSplitArr []string, index int, leaves []*node) []*node {
+	if len(patternSplitArr) == index { // splitArr长度与index相同则表明当前结点是叶子结点
+		if n.hasRoutes() {
+			leaves = append(leaves, n)
+		}
+		return leaves
+	}
 	patternPiece := patternSplitArr[index]
 	index++
-	nChanStaticPiece := make(chan *node)
-	nChanDynamicPiece := make(chan *node)
-	count := 2
-	go func() {
-		nChanStaticPiece <- n.fetchFuncAsync(pattern, patternPiece, method, patternSplitArr, index)
-	}()
-	go func() {
-		nChanDynamicPiece <- n.fetchFuncAsync(pattern, "?", method, patternSplitArr, index)
-	}()
-	for {
-		select {
-		case nd := <-nChanStaticPiece:
-			count--
-			if nil != nd {
-				return nd
-			} else if count == 0 {
-				return nil
-			}
-		case nd := <-nChanDynamicPiece:
-			count--
-			if nil != nd {
-				return nd
-			} else if count == 0 {
-				return nil
-			}
+	nextNodes := n.children()
+	for _, nd := range nextNodes {
+		if nd.static() && nd.patternPiece == patternPiece {
+			leaves = nd.search(patternSplitArr, index, leaves)
+		}
+	}
+	for _, nd := range nextNodes {
+		if nil != nd.matcher && nd.matcher.MatchString(patternPiece) {
+			leaves = nd.search(patternSplitArr, index, leaves)
+		}
+	}
+	for _, nd := range nextNodes {
+		if nd.patternPiece == "?" {
+			leaves = nd.search(patternSplitArr, index, leaves)
 		}
 	}
+	for _, nd := range nextNodes {
+		if nd.patternPiece == "*" && nd.hasRoutes() {
+			leaves = append(leaves, nd)
+		}
+	}
+	return leaves
 }
 
-func (n *node) fetchFuncAsync(pattern, patternPiece string, method string, patternSplitArr []string, index int) *node {
-	for _, nd := range n.nextNodes {
-		if nd.patternPiece == patternPiece {
-			if len(patternSplitArr) == index { // splitArr长度与index相同则表明当前结点是叶子结点
-				if nd.method == method {
-					return nd
-				}
-				continue
-			}
-			if rn := nd.fetchSplitArr(pattern, method, patternSplitArr, index); nil != rn && rn.method == method {
-				return rn
-			}
+// static 是否为静态结点
+func (n *node) static() bool {
+	return len(n.patternPiece) == 0 || (n.patternPiece[0] != '?' && n.patternPiece[0] != '*')
+}
+
+// parseParams 解析项目路径中的泛型参数
+//
+// pattern /a/b/:c/d/:e<int>/*f
+func parseParams(pattern string) []*routeParam {
+	var params []*routeParam
+	for index, patternPiece := range strings.Split(pattern, "/")[1:] {
+		if len(patternPiece) == 0 || (patternPiece[0] != ':' && patternPiece[0] != '*') {
+			continue
+		}
+		name, _ := parseParam(patternPiece)
+		params = append(params, &routeParam{index: index, name: name, catchAll: patternPiece[0] == '*'})
+	}
+	return params
+}
+
+// values 根据请求路径片段获取泛型参数值
+//
+// patternSplitArr [a, b, c, d, e, f, g]
+func (r *route) values(patternSplitArr []string, valueMap map[string]string) {
+	for _, param := range r.params {
+		if param.index >= len(patternSplitArr) {
+			continue
+		}
+		if param.catchAll {
+			valueMap[param.name] = strings.Join(patternSplitArr[param.index:], "/")
+		} else {
+			valueMap[param.name] = patternSplitArr[param.index]
+		}
+	}
+}
+
+func (n *node) children() []*node {
+	defer n.lockNode.Unlock()
+	n.lockNode.Lock()
+	return n.nextNodes
+}
+
+func (n *node) hasRoutes() bool {
+	defer n.lockNode.Unlock()
+	n.lockNode.Lock()
+	return len(n.routes) > 0
+}
+
+func (n *node) route(method string) *route {
+	defer n.lockNode.Unlock()
+	n.lockNode.Lock()
+	return n.routes[method]
+}
+
+// methods 当前结点已设置路由的请求方法
+func (n *node) methods() []string {
+	defer n.lockNode.Unlock()
+	n.lockNode.Lock()
+	methods := make([]string, 0, len(n.routes))
+	for method := range n.routes {
+		methods = append(methods, method)
+	}
+	return methods
+}
+
+// matchRoute 按顺序在叶子结点中查找指定请求方法的路由
+func matchRoute(leaves []*node, method string) *route {
+	for _, leaf := range leaves {
+		if r := leaf.route(method); nil != r {
+			return r
 		}
 	}
 	return nil
 }
 
+// allowRoute 生成请求路径匹配但请求方法不匹配时的路由
+//
+// 请求方法为 OPTIONS 时应答 204 No Content，否则应答 405 Method Not Allowed，均携带 Allow 头部信息，项目路径沿用叶子结点中的路由
+func allowRoute(leaves []*node, method string) *route {
+	allowMap := map[string]bool{http.MethodOptions: true}
+	for _, leaf := range leaves {
+		for _, m := range leaf.methods() {
+			allowMap[m] = true
+		}
+	}
+	if allowMap[http.MethodGet] {
+		allowMap[http.MethodHead] = true
+	}
+	allows := make([]string, 0, len(allowMap))
+	for m := range allowMap {
+		allows = append(allows, m)
+	}
+	sort.Strings(allows)
+	allow := strings.Join(allows, ", ")
+	var pattern string
+	if methods := leaves[0].methods(); len(methods) > 0 {
+		sort.Strings(methods)
+		pattern = leaves[0].route(methods[0]).pattern
+	}
+	return &route{
+		pattern: pattern,
+		method:  method,
+		leaf:    leaves[0],
+		allowed: true,
+		handler: func(ctx *Context) {
+			ctx.HeaderSet("Allow", allow)
+			ctx.responded = true
+			if method == http.MethodOptions {
+				ctx.Status(http.StatusNoContent)
+			} else {
+				ctx.Status(http.StatusMethodNotAllowed)
+			}
+		},
+	}
+}
+
+// walk 遍历当前结点及其子结点中的所有路由
+func (n *node) walk(fn func(r *route)) {
+	n.lockNode.Lock()
+	routes := make([]*route, 0, len(n.routes))
+	for _, r := range n.routes {
+		routes = append(routes, r)
+	}
+	nextNodes := n.nextNodes
+	n.lockNode.Unlock()
+	for _, r := range routes {
+		fn(r)
+	}
+	for _, nd := range nextNodes {
+		nd.walk(fn)
+	}
+}
+
+// RouteInfo 已注册路由信息
+type RouteInfo struct {
+	Method  string   // eg:http.MethodGet
+	Pattern string   // eg:“/demo/:id/:name”
+	Filters []string // 过滤器/拦截器方法名称，按执行顺序排列
+	Extend  *Extend  // 扩展方案，如限流等
+	Proxy   bool     // 是否为反向代理
+}
+
+// info 生成路由信息
+func (r *route) info() *RouteInfo {
+	chain, extend := r.chain()
+	filters := make([]string, len(chain))
+	for index, filter := range chain {
+		filters[index] = runtime.FuncForPC(reflect.ValueOf(filter).Pointer()).Name()
+	}
+	return &RouteInfo{
+		Method:  r.method,
+		Pattern: r.pattern,
+		Filters: filters,
+		Extend:  extend,
+		Proxy:   nil != r.proxy,
+	}
+}
+
+// chain 获取路由生效的过滤器/拦截器及扩展方案
+//
+// 过滤器/拦截器按服务、外层分组、内层分组、路由自身的顺序执行；扩展方案中越内层设置的字段优先级越高，路由自身最高
+func (r *route) chain() ([]Filter, *Extend) {
+	var nodes []*node
+	for nd := r.leaf; nil != nd; nd = nd.preNode {
+		nodes = append(nodes, nd)
+	}
+	var (
+		filters []Filter
+		extend  *Extend
+	)
+	for index := len(nodes) - 1; index >= 0; index-- {
+		nd := nodes[index]
+		nd.lockNode.Lock()
+		filters = append(filters, nd.filters...)
+		extend = extend.merge(nd.extend)
+		nd.lockNode.Unlock()
+	}
+	return append(filters, r.filters...), extend.merge(r.extend)
+}
+
 // parseHandler 解析请求处理方法
-func (n *node) parseHandler(ctx *Context) {
+func (r *route) parseHandler(ctx *Context) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Error("parseHandler", log.Field("error", err))
-			ctx.Status(http.StatusInternalServerError)
+			ctx.recoverPanic(err)
 		}
 	}()
-	if nil != n.proxy {
-		// todo 反向代理
+	if nil != r.proxy {
+		r.proxy.serve(ctx)
 	} else {
-		n.handler(ctx)
+		r.handler(ctx)
 	}
 }
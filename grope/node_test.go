@@ -16,7 +16,10 @@ package grope
 
 import (
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNodeSupport(t *testing.T) {
@@ -78,10 +81,184 @@ func TestNodeQA(t *testing.T) {
 	printNode(root.fetch("/v1/company/1/platforms/2", http.MethodPut), t)
 }
 
-func printNode(n *node, t *testing.T) {
-	if nil == n {
+func printNode(r *route, t *testing.T) {
+	if nil == r {
 		t.Log("none")
 	} else {
-		t.Log(n.method, n.patternPiece, n.pattern)
+		t.Log(r.method, r.pattern)
+	}
+}
+
+func TestNodeMethods(t *testing.T) {
+	hs := NewHTTPServe()
+	route := hs.Group("/v1")
+	route.Get("/users/:id", func(ctx *Context) { _ = ctx.ResponseText(http.StatusOK, "get "+ctx.Value("id")) })
+	route.Delete("/users/:id", func(ctx *Context) { _ = ctx.ResponseText(http.StatusOK, "delete "+ctx.Value("id")) })
+	route.Post("/users/me", func(ctx *Context) { _ = ctx.ResponseText(http.StatusOK, "post me") })
+
+	cases := []struct {
+		method, path string
+		status       int
+		body, allow  string
+	}{
+		{http.MethodGet, "/v1/users/1", http.StatusOK, "get 1", ""},
+		{http.MethodDelete, "/v1/users/2", http.StatusOK, "delete 2", ""},
+		{http.MethodGet, "/v1/users/me", http.StatusOK, "get me", ""},
+		{http.MethodPost, "/v1/users/me", http.StatusOK, "post me", ""},
+		{http.MethodHead, "/v1/users/1", http.StatusOK, "get 1", ""},
+		{http.MethodPut, "/v1/users/1", http.StatusMethodNotAllowed, "", "DELETE, GET, HEAD, OPTIONS"},
+		{http.MethodOptions, "/v1/users/me", http.StatusNoContent, "", "DELETE, GET, HEAD, OPTIONS, POST"},
+		{http.MethodGet, "/v1/groups/1", http.StatusNotFound, "404 page not found\n", ""},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		hs.ServeHTTP(rec, httptest.NewRequest(c.method, c.path, nil))
+		if rec.Code != c.status || rec.Body.String() != c.body || rec.Header().Get("Allow") != c.allow {
+			t.Errorf("%s %s = %d %q allow %q", c.method, c.path, rec.Code, rec.Body.String(), rec.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestNodeConflict(t *testing.T) {
+	hs := NewHTTPServe()
+	route := hs.Group("/v1", printFilter)
+	if err := route.Get("/users/:id", func(ctx *Context) {}); nil != err {
+		t.Fatal(err)
+	}
+	if err := route.Delete("/users/:name", func(ctx *Context) {}); nil != err {
+		t.Fatal(err)
+	}
+	if err := route.Get("/users/:name", func(ctx *Context) {}); nil == err {
+		t.Fatal("expected conflict error")
+	} else {
+		t.Log(err)
+	}
+	if err := route.Gets("/users/:id", &Extend{}, func(ctx *Context) {}); nil == err {
+		t.Fatal("expected duplicate error")
+	} else {
+		t.Log(err)
+	}
+	routes := hs.Routes()
+	if len(routes) != 2 {
+		t.Fatalf("routes = %d", len(routes))
+	}
+	if routes[0].Method != http.MethodGet || routes[0].Pattern != "/v1/users/:id" ||
+		routes[1].Method != http.MethodDelete || routes[1].Pattern != "/v1/users/:name" {
+		t.Fatalf("routes = %+v %+v", routes[0], routes[1])
+	}
+	if len(routes[0].Filters) != 1 || routes[0].Filters[0] != "github.com/aberic/gnomon/grope.printFilter" {
+		t.Fatalf("filters = %v", routes[0].Filters)
+	}
+}
+
+func printFilter(ctx *Context) {}
+
+func TestNodeParams(t *testing.T) {
+	hs := NewHTTPServe()
+	route := hs.Group("/v2")
+	echo := func(ctx *Context) {
+		_ = ctx.ResponseText(http.StatusOK, ctx.Value("id")+ctx.Value("slug")+ctx.Value("filepath"))
+	}
+	_ = route.Get("/items/:id<int>", echo)
+	_ = route.Get("/items/:slug<[a-z-]+>", echo)
+	_ = route.Get("/items/new", func(ctx *Context) { _ = ctx.ResponseText(http.StatusOK, "new") })
+	_ = route.Get("/files/*filepath", echo)
+	if err := route.Get("/bad/*filepath/more", echo); nil == err {
+		t.Fatal("expected catch-all error")
+	}
+	if err := route.Get("/bad/:id<[>", echo); nil == err {
+		t.Fatal("expected regexp error")
+	}
+
+	for path, body := range map[string]string{
+		"/v2/items/42":          "42",
+		"/v2/items/hello-world": "hello-world",
+		"/v2/items/new":         "new",
+		"/v2/files/a/b/c.txt":   "a/b/c.txt",
+	} {
+		rec := httptest.NewRecorder()
+		hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != body {
+			t.Errorf("%s = %d %q", path, rec.Code, rec.Body.String())
+		}
+	}
+	rec := httptest.NewRecorder()
+	hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v2/items/Bad_1", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unconstrained = %d", rec.Code)
+	}
+}
+
+func TestNodeGroup(t *testing.T) {
+	var trace []string
+	mark := func(name string) Filter {
+		return func(ctx *Context) { trace = append(trace, name) }
+	}
+	groupLimit := &Limit{LimitMillisecond: 60000, LimitCount: 1}
+	hs := NewHTTPServe(mark("serve"))
+	api := hs.Group("/api", mark("api"))
+	api.SetExtend(&Extend{Limit: groupLimit, Timeout: time.Minute})
+	v1 := api.Group("/v1", mark("v1"))
+	handler := func(ctx *Context) {
+		deadline, ok := ctx.Request().Context().Deadline()
+		trace = append(trace, "handler")
+		_ = ctx.ResponseText(http.StatusOK, time.Until(deadline).Round(time.Minute).String()+" "+
+			map[bool]string{true: "deadline", false: "none"}[ok])
+	}
+	_ = v1.Get("/users", handler, mark("route"))
+	_ = v1.Gets("/items", &Extend{Timeout: time.Hour}, handler)
+	api.Use(mark("late"))
+	hs.Use(mark("global"))
+
+	cases := []struct {
+		path   string
+		status int
+		trace  string
+		body   string
+	}{
+		{"/api/v1/users", http.StatusOK, "serve global api late v1 route handler", "1m0s deadline"},
+		{"/api/v1/items", http.StatusTooManyRequests, "", ""},
+	}
+	for _, c := range cases {
+		trace = nil
+		rec := httptest.NewRecorder()
+		hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if rec.Code != c.status || strings.Join(trace, " ") != c.trace || (c.body != "" && rec.Body.String() != c.body) {
+			t.Errorf("%s = %d %q %q", c.path, rec.Code, strings.Join(trace, " "), rec.Body.String())
+		}
+	}
+
+	groupLimit.LimitCount = 2
+	trace = nil
+	rec := httptest.NewRecorder()
+	hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/items", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "1h0m0s deadline" {
+		t.Errorf("items = %d %q", rec.Code, rec.Body.String())
+	}
+	routes := hs.Routes()
+	if len(routes) != 2 || len(routes[1].Filters) != 6 || routes[1].Extend.Limit != groupLimit || routes[1].Extend.Timeout != time.Minute {
+		t.Errorf("routes = %+v", routes[1])
+	}
+}
+
+func TestNodeGroupInvalid(t *testing.T) {
+	hs := NewHTTPServe()
+	cases := map[string]func(){
+		"serve group":  func() { hs.Group("/files/*filepath/v1") },
+		"router group": func() { hs.Group("/api").Group("/*filepath/v1") },
+		"router use":   func() { (&GHttpRouter{pattern: "/*filepath/use", nodal: hs.nodal}).Use() },
+		"router extend": func() {
+			(&GHttpRouter{pattern: "/*filepath/extend", nodal: hs.nodal}).SetExtend(&Extend{Timeout: time.Minute})
+		},
+	}
+	for name, register := range cases {
+		func() {
+			defer func() {
+				if err, ok := recover().(error); !ok || !strings.Contains(err.Error(), "catch-all must be the last segment") {
+					t.Errorf("%s: expected catch-all panic, got %v", name, err)
+				}
+			}()
+			register()
+		}()
 	}
 }
@@ -0,0 +1,134 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"errors"
+	"github.com/aberic/gnomon"
+	"github.com/aberic/gnomon/balance"
+	"github.com/aberic/gnomon/log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// proxyMethods 反向代理默认接收的请求方法
+	proxyMethods = []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+		http.MethodOptions,
+		http.MethodTrace,
+	}
+	// proxyTransport 反向代理使用的传输配置
+	proxyTransport = &Transport{
+		Timeout:               30 * time.Second,
+		KeepAlive:             30 * time.Second,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		MaxIdleConnsPerHost:   100,
+	}
+	// ErrProxyTarget proxy target is empty
+	ErrProxyTarget = errors.New("proxy target is empty")
+)
+
+// init 根据负载模型及代理目标结构生成负载均衡器，仅执行一次
+func (p *Proxy) init() {
+	p.once.Do(func() {
+		p.balancer = balance.NewBalance(p.Balance)
+		for _, target := range p.Target {
+			if nil == target {
+				continue
+			}
+			p.balancer.Add(target)
+			if target.Weight > 0 {
+				p.balancer.Weight(target, target.Weight)
+			}
+		}
+	})
+}
+
+// acquire 通过负载均衡器获取本次请求的代理目标
+func (p *Proxy) acquire() (*Target, error) {
+	p.init()
+	if len(p.Target) == 0 {
+		return nil, ErrProxyTarget
+	}
+	p.lock.Lock()
+	obj, err := p.balancer.Acquire()
+	p.lock.Unlock()
+	if nil != err {
+		return nil, err
+	}
+	return obj.(*Target), nil
+}
+
+// serve 将请求转发至负载均衡选出的代理目标，并将目标的响应回写客户端
+func (p *Proxy) serve(ctx *Context) {
+	target, err := p.acquire()
+	if nil == err {
+		err = ctx.forward(target.url(ctx), proxyTransport)
+	}
+	if nil != err {
+		ctx.LogError("proxy", log.Err(err))
+		if !ctx.responded {
+			ctx.responded = true
+			ctx.Status(http.StatusBadGateway)
+		}
+	}
+}
+
+// url 根据代理目标生成本次请求的真实地址
+//
+// Pattern 中的“:id”、“:id<int>”及“*filepath”等泛型参数将被请求中对应的值转义后替换，如未设置 Pattern 则沿用转义后的请求路径
+func (t *Target) url(ctx *Context) string {
+	var path string
+	if gnomon.StringIsEmpty(t.Pattern) {
+		path = ctx.request.URL.EscapedPath()
+	} else {
+		pieces := strings.Split(t.Pattern, "/")
+		for index, piece := range pieces {
+			if strings.HasPrefix(piece, ":") || strings.HasPrefix(piece, "*") {
+				name, _ := parseParam(piece)
+				pieces[index] = proxyEscape(ctx.valueMap[name])
+			}
+		}
+		path = strings.Join(pieces, "/")
+	}
+	host := t.Host
+	if gnomon.StringIsNotEmpty(t.Port) {
+		host = gnomon.StringBuild(host, ":", t.Port)
+	}
+	if gnomon.StringIsEmpty(ctx.request.URL.RawQuery) {
+		return gnomon.StringBuild("http://", host, path)
+	}
+	return gnomon.StringBuild("http://", host, path, "?", ctx.request.URL.RawQuery)
+}
+
+// proxyEscape 逐段转义路径参数值，保留“*filepath”等通配参数值中的“/”
+func proxyEscape(value string) string {
+	segments := strings.Split(value, "/")
+	for index, segment := range segments {
+		segments[index] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"github.com/aberic/gnomon/balance"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestProxies(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream", "yes")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(r.Method + " " + r.URL.RequestURI()))
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+
+	hs := NewHTTPServe()
+	route := hs.Group("/proxy")
+	route.Proxies("/user/:id/:name", &Proxy{
+		Balance: balance.Round,
+		Target: []*Target{
+			{Host: host, Port: port, Pattern: "/real/:name/:id", Weight: 2},
+		},
+	}, nil)
+
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodDelete} {
+		rec := httptest.NewRecorder()
+		hs.ServeHTTP(rec, httptest.NewRequest(method, "/proxy/user/1/hello?a=b", nil))
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("%s status = %d", method, rec.Code)
+		}
+		if rec.Header().Get("X-Upstream") != "yes" {
+			t.Fatalf("%s upstream header lost", method)
+		}
+		if body := rec.Body.String(); body != method+" /real/hello/1?a=b" {
+			t.Fatalf("%s body = %s", method, body)
+		}
+	}
+}
+
+func TestProxiesParams(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+
+	hs := NewHTTPServe()
+	route := hs.Group("/proxy")
+	route.Proxies("/item/:id<int>", &Proxy{
+		Balance: balance.Round,
+		Target:  []*Target{{Host: host, Port: port, Pattern: "/real/:id<int>"}},
+	}, nil)
+	route.Proxies("/files/*path", &Proxy{
+		Balance: balance.Round,
+		Target:  []*Target{{Host: host, Port: port, Pattern: "/static/*path"}},
+	}, nil)
+
+	for path, expect := range map[string]string{
+		"/proxy/item/42":      "/real/42",
+		"/proxy/files/a/b.js": "/static/a/b.js",
+	} {
+		rec := httptest.NewRecorder()
+		hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if body := rec.Body.String(); rec.Code != http.StatusOK || body != expect {
+			t.Fatalf("%s: status = %d, body = %s", path, rec.Code, body)
+		}
+	}
+}
+
+func TestProxiesEscape(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path + "|" + r.URL.RawQuery))
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+
+	hs := NewHTTPServe()
+	route := hs.Group("/proxy")
+	route.Proxies("/item/:name", &Proxy{
+		Balance: balance.Round,
+		Target:  []*Target{{Host: host, Port: port, Pattern: "/real/:name"}},
+	}, nil)
+	route.Proxies("/raw/:name", &Proxy{
+		Balance: balance.Round,
+		Target:  []*Target{{Host: host, Port: port}},
+	}, nil)
+
+	for path, expect := range map[string]string{
+		"/proxy/item/a%3Fb%23c%25d?q=1": "/real/a?b#c%d|q=1",
+		"/proxy/raw/a%3Fb%20c":          "/proxy/raw/a?b c|",
+	} {
+		rec := httptest.NewRecorder()
+		hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if body := rec.Body.String(); rec.Code != http.StatusOK || body != expect {
+			t.Errorf("%s: status = %d, body = %s", path, rec.Code, body)
+		}
+	}
+}
+
+func TestProxiesConcurrent(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+
+	hs := NewHTTPServe()
+	hs.Group("/proxy").Proxies("/smooth", &Proxy{
+		Balance: balance.Smooth,
+		Target: []*Target{
+			{Host: host, Port: port, Pattern: "/a", Weight: 1},
+			{Host: host, Port: port, Pattern: "/b", Weight: 3},
+		},
+	}, nil)
+	var wg sync.WaitGroup
+	for index := 0; index < 16; index++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for count := 0; count < 10; count++ {
+				rec := httptest.NewRecorder()
+				hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/proxy/smooth", nil))
+				if rec.Code != http.StatusOK {
+					t.Errorf("status = %d", rec.Code)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestProxiesRedirect(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			_, _ = w.Write([]byte("followed"))
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+
+	hs := NewHTTPServe()
+	route := hs.Group("/proxy")
+	route.Proxies("/jump", &Proxy{
+		Balance: balance.Round,
+		Target:  []*Target{{Host: host, Port: port, Pattern: "/jump"}},
+	}, nil)
+
+	rec := httptest.NewRecorder()
+	hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/proxy/jump", nil))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/login" {
+		t.Fatalf("status = %d, location = %s", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestProxiesBadGateway(t *testing.T) {
+	hs := NewHTTPServe()
+	route := hs.Group("/proxy")
+	route.Proxies("/down", &Proxy{
+		Balance: balance.Random,
+		Target:  []*Target{{Host: "127.0.0.1", Port: "1", Pattern: "/down"}},
+	}, nil)
+
+	rec := httptest.NewRecorder()
+	hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/proxy/down", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d", rec.Code)
+	}
+}
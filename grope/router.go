@@ -18,6 +18,8 @@ import (
 	"github.com/aberic/gnomon"
 	"github.com/aberic/gnomon/balance"
 	"net/http"
+	"sync"
+	"time"
 )
 
 // Handler 待实现接收请求方法
@@ -27,18 +29,42 @@ type Handler func(ctx *Context)
 
 // Filter 过滤器/拦截器处理
 //
+// 过滤器/拦截器可通过 ctx.Next() 执行后续调用链并在其返回后进行后置处理，通过 ctx.Abort() 或写入响应中止调用链
+//
 // ctx 请求处理上下文结构
 type Filter func(ctx *Context)
 
-// Extend 请求扩展
+// Extend 请求扩展，可设置于路由分组或路由，内层设置的非空字段覆盖外层设置
 type Extend struct {
-	Limit *Limit
+	Limit   *Limit        // 限流策略，设置于路由分组时由分组内全部路由共享额度
+	Timeout time.Duration // 请求处理超时时间，超时后 Context.Request().Context() 被取消，0表示不限
+}
+
+// merge 以over中的非空字段覆盖e，返回新的扩展方案，均为nil时返回nil
+func (e *Extend) merge(over *Extend) *Extend {
+	if nil == over {
+		return e
+	}
+	if nil == e {
+		return over
+	}
+	merged := *e
+	if nil != over.Limit {
+		merged.Limit = over.Limit
+	}
+	if over.Timeout > 0 {
+		merged.Timeout = over.Timeout
+	}
+	return &merged
 }
 
 // Proxy 请求代理结构，目前仅支持HTTP
 type Proxy struct {
-	Balance balance.Class // 负载模型
-	Target  []*Target     // 代理目标结构
+	Balance  balance.Class    // 负载模型
+	Target   []*Target        // 代理目标结构
+	balancer balance.Balancer // 根据负载模型及代理目标结构生成的负载均衡器
+	once     sync.Once
+	lock     sync.Mutex // 负载均衡器并非协程安全，获取代理目标时须持有锁
 }
 
 // Target 代理目标结构
@@ -55,8 +81,37 @@ type GHttpRouter struct {
 	nodal   *node
 }
 
-func (ghr *GHttpRouter) repo(method, pattern string, extend *Extend, handler Handler, proxy *Proxy, filters ...Filter) {
-	ghr.nodal.add(gnomon.StringBuild(ghr.pattern, pattern), method, extend, handler, proxy, filters...)
+// Group 在当前路由分组下创建子分组，可任意嵌套
+//
+// pattern 子分组路径，如“/v1”，与当前分组路径相结合
+//
+// filters 子分组的过滤器/拦截器，在当前分组的过滤器/拦截器之后执行
+//
+// 子分组路径非法（如“*filepath”不在末尾）时panic
+func (ghr *GHttpRouter) Group(pattern string, filters ...Filter) *GHttpRouter {
+	pattern = gnomon.StringBuild(ghr.pattern, pattern)
+	if err := ghr.nodal.add(pattern, "", nil, nil, nil, filters...); nil != err {
+		panic(err)
+	}
+	return &GHttpRouter{pattern: pattern, nodal: ghr.nodal}
+}
+
+// Use 为当前路由分组追加过滤器/拦截器，对分组内已注册及之后注册的路由均生效
+func (ghr *GHttpRouter) Use(filters ...Filter) {
+	if err := ghr.nodal.add(ghr.pattern, "", nil, nil, nil, filters...); nil != err {
+		panic(err)
+	}
+}
+
+// SetExtend 设置当前路由分组的扩展方案，分组内路由未设置的字段继承该方案
+func (ghr *GHttpRouter) SetExtend(extend *Extend) {
+	if err := ghr.nodal.add(ghr.pattern, "", extend, nil, nil); nil != err {
+		panic(err)
+	}
+}
+
+func (ghr *GHttpRouter) repo(method, pattern string, extend *Extend, handler Handler, proxy *Proxy, filters ...Filter) error {
+	return ghr.nodal.add(gnomon.StringBuild(ghr.pattern, pattern), method, extend, handler, proxy, filters...)
 }
 
 // execURL 特殊处理Url
@@ -100,8 +155,10 @@ func (ghr *GHttpRouter) repo(method, pattern string, extend *Extend, handler Han
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Get(pattern string, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodGet, pattern, nil, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Get(pattern string, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodGet, pattern, nil, handler, nil, filters...)
 }
 
 // Head 发起一个 Head 请求接收项目
@@ -117,8 +174,10 @@ func (ghr *GHttpRouter) Get(pattern string, handler Handler, filters ...Filter)
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Head(pattern string, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodHead, pattern, nil, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Head(pattern string, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodHead, pattern, nil, handler, nil, filters...)
 }
 
 // Post 发起一个 Post 请求接收项目
@@ -133,8 +192,10 @@ func (ghr *GHttpRouter) Head(pattern string, handler Handler, filters ...Filter)
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Post(pattern string, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodPost, pattern, nil, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Post(pattern string, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodPost, pattern, nil, handler, nil, filters...)
 }
 
 // Put 发起一个 Put 请求接收项目
@@ -148,8 +209,10 @@ func (ghr *GHttpRouter) Post(pattern string, handler Handler, filters ...Filter)
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Put(pattern string, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodPut, pattern, nil, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Put(pattern string, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodPut, pattern, nil, handler, nil, filters...)
 }
 
 // Patch 发起一个 Patch 请求接收项目
@@ -165,8 +228,10 @@ func (ghr *GHttpRouter) Put(pattern string, handler Handler, filters ...Filter)
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Patch(pattern string, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodPatch, pattern, nil, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Patch(pattern string, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodPatch, pattern, nil, handler, nil, filters...)
 }
 
 // Delete 发起一个 Delete 请求接收项目
@@ -181,8 +246,10 @@ func (ghr *GHttpRouter) Patch(pattern string, handler Handler, filters ...Filter
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Delete(pattern string, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodDelete, pattern, nil, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Delete(pattern string, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodDelete, pattern, nil, handler, nil, filters...)
 }
 
 // Connect 发起一个 Connect 请求接收项目
@@ -196,8 +263,10 @@ func (ghr *GHttpRouter) Delete(pattern string, handler Handler, filters ...Filte
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Connect(pattern string, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodConnect, pattern, nil, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Connect(pattern string, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodConnect, pattern, nil, handler, nil, filters...)
 }
 
 // Option 发起一个 Options 请求接收项目
@@ -213,8 +282,10 @@ func (ghr *GHttpRouter) Connect(pattern string, handler Handler, filters ...Filt
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Option(pattern string, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodOptions, pattern, nil, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Option(pattern string, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodOptions, pattern, nil, handler, nil, filters...)
 }
 
 // Trace 发起一个 Trace 请求接收项目
@@ -228,8 +299,10 @@ func (ghr *GHttpRouter) Option(pattern string, handler Handler, filters ...Filte
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Trace(pattern string, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodTrace, pattern, nil, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Trace(pattern string, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodTrace, pattern, nil, handler, nil, filters...)
 }
 
 // Gets 发起一个 Get 请求接收项目
@@ -244,8 +317,10 @@ func (ghr *GHttpRouter) Trace(pattern string, handler Handler, filters ...Filter
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Gets(pattern string, extend *Extend, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodGet, pattern, extend, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Gets(pattern string, extend *Extend, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodGet, pattern, extend, handler, nil, filters...)
 }
 
 // Heads 发起一个 Head 请求接收项目
@@ -261,8 +336,10 @@ func (ghr *GHttpRouter) Gets(pattern string, extend *Extend, handler Handler, fi
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Heads(pattern string, extend *Extend, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodHead, pattern, extend, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Heads(pattern string, extend *Extend, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodHead, pattern, extend, handler, nil, filters...)
 }
 
 // Posts 发起一个 Post 请求接收项目
@@ -277,8 +354,10 @@ func (ghr *GHttpRouter) Heads(pattern string, extend *Extend, handler Handler, f
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Posts(pattern string, extend *Extend, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodPost, pattern, extend, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Posts(pattern string, extend *Extend, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodPost, pattern, extend, handler, nil, filters...)
 }
 
 // Puts 发起一个 Put 请求接收项目
@@ -292,8 +371,10 @@ func (ghr *GHttpRouter) Posts(pattern string, extend *Extend, handler Handler, f
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Puts(pattern string, extend *Extend, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodPut, pattern, extend, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Puts(pattern string, extend *Extend, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodPut, pattern, extend, handler, nil, filters...)
 }
 
 // Patches 发起一个 Patch 请求接收项目
@@ -309,8 +390,10 @@ func (ghr *GHttpRouter) Puts(pattern string, extend *Extend, handler Handler, fi
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Patches(pattern string, extend *Extend, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodPatch, pattern, extend, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Patches(pattern string, extend *Extend, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodPatch, pattern, extend, handler, nil, filters...)
 }
 
 // Deletes 发起一个 Delete 请求接收项目
@@ -325,8 +408,10 @@ func (ghr *GHttpRouter) Patches(pattern string, extend *Extend, handler Handler,
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Deletes(pattern string, extend *Extend, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodDelete, pattern, extend, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Deletes(pattern string, extend *Extend, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodDelete, pattern, extend, handler, nil, filters...)
 }
 
 // Connects 发起一个 Connect 请求接收项目
@@ -340,8 +425,10 @@ func (ghr *GHttpRouter) Deletes(pattern string, extend *Extend, handler Handler,
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Connects(pattern string, extend *Extend, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodConnect, pattern, extend, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Connects(pattern string, extend *Extend, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodConnect, pattern, extend, handler, nil, filters...)
 }
 
 // Options 发起一个 Options 请求接收项目
@@ -357,8 +444,10 @@ func (ghr *GHttpRouter) Connects(pattern string, extend *Extend, handler Handler
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Options(pattern string, extend *Extend, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodOptions, pattern, extend, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Options(pattern string, extend *Extend, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodOptions, pattern, extend, handler, nil, filters...)
 }
 
 // Traces 发起一个 Trace 请求接收项目
@@ -372,8 +461,10 @@ func (ghr *GHttpRouter) Options(pattern string, extend *Extend, handler Handler,
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Traces(pattern string, extend *Extend, handler Handler, filters ...Filter) {
-	go ghr.repo(http.MethodTrace, pattern, extend, handler, nil, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Traces(pattern string, extend *Extend, handler Handler, filters ...Filter) error {
+	return ghr.repo(http.MethodTrace, pattern, extend, handler, nil, filters...)
 }
 
 // Proxies 发起一个 Proxy 请求接收项目
@@ -387,6 +478,13 @@ func (ghr *GHttpRouter) Traces(pattern string, extend *Extend, handler Handler,
 // handler 待实现接收请求方法
 //
 // filters 待实现拦截器/过滤器方法数组
-func (ghr *GHttpRouter) Proxies(pattern string, proxy *Proxy, extend *Extend, filters ...Filter) {
-	go ghr.repo(http.MethodTrace, pattern, extend, nil, proxy, filters...)
+//
+// 相同请求方法下已存在相同或冲突(如“/demo/:id”与“/demo/:name”)的项目路径时返回错误
+func (ghr *GHttpRouter) Proxies(pattern string, proxy *Proxy, extend *Extend, filters ...Filter) error {
+	for _, method := range proxyMethods {
+		if err := ghr.repo(method, pattern, extend, nil, proxy, filters...); nil != err {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"github.com/aberic/gnomon"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SecureConfig 安全响应头策略，字段为空时不设置对应的响应头
+type SecureConfig struct {
+	HSTSMaxAge              time.Duration // “Strict-Transport-Security”有效期，仅在https请求中设置，0表示不设置
+	HSTSIncludeSubdomains   bool          // HSTS是否包含子域名
+	HSTSPreload             bool          // HSTS是否允许加入浏览器预加载列表
+	ContentSecurityPolicy   string        // “Content-Security-Policy”
+	FrameOptions            string        // “X-Frame-Options”，如“DENY”或“SAMEORIGIN”
+	ContentTypeNosniff      bool          // 是否设置“X-Content-Type-Options: nosniff”
+	ReferrerPolicy          string        // “Referrer-Policy”
+	PermissionsPolicy       string        // “Permissions-Policy”
+	CrossOriginOpenerPolicy string        // “Cross-Origin-Opener-Policy”
+}
+
+// DefaultSecureConfig 默认安全响应头策略
+//
+// HSTS有效期1年并包含子域名，禁止嵌入frame，禁止MIME类型嗅探，跨域时仅发送来源
+func DefaultSecureConfig() *SecureConfig {
+	return &SecureConfig{
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+		FrameOptions:          "DENY",
+		ContentTypeNosniff:    true,
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+	}
+}
+
+// hsts 生成“Strict-Transport-Security”的值
+func (sc *SecureConfig) hsts() string {
+	if sc.HSTSMaxAge <= 0 {
+		return ""
+	}
+	hsts := gnomon.StringBuild("max-age=", strconv.FormatInt(int64(sc.HSTSMaxAge/time.Second), 10))
+	if sc.HSTSIncludeSubdomains {
+		hsts = gnomon.StringBuild(hsts, "; includeSubDomains")
+	}
+	if sc.HSTSPreload {
+		hsts = gnomon.StringBuild(hsts, "; preload")
+	}
+	return hsts
+}
+
+// SecureHeaders 安全响应头过滤器/拦截器，config为nil时使用 DefaultSecureConfig
+//
+// “Strict-Transport-Security”仅在TLS请求或“X-Forwarded-Proto: https”的请求中设置
+func SecureHeaders(config *SecureConfig) Filter {
+	if nil == config {
+		config = DefaultSecureConfig()
+	}
+	hsts := config.hsts()
+	headers := map[string]string{
+		"Content-Security-Policy":    config.ContentSecurityPolicy,
+		"X-Frame-Options":            config.FrameOptions,
+		"Referrer-Policy":            config.ReferrerPolicy,
+		"Permissions-Policy":         config.PermissionsPolicy,
+		"Cross-Origin-Opener-Policy": config.CrossOriginOpenerPolicy,
+	}
+	if config.ContentTypeNosniff {
+		headers["X-Content-Type-Options"] = "nosniff"
+	}
+	return func(ctx *Context) {
+		header := ctx.writer.Header()
+		for key, value := range headers {
+			if gnomon.StringIsNotEmpty(value) {
+				header.Set(key, value)
+			}
+		}
+		if gnomon.StringIsNotEmpty(hsts) &&
+			(nil != ctx.request.TLS || strings.EqualFold(ctx.HeaderGet("X-Forwarded-Proto"), "https")) {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecureHeaders(t *testing.T) {
+	config := DefaultSecureConfig()
+	config.ContentSecurityPolicy = "default-src 'self'"
+	hs := NewHTTPServe(SecureHeaders(config))
+	_ = hs.Group("/secure").Get("/page", func(ctx *Context) {
+		_ = ctx.ResponseText(http.StatusOK, "ok")
+	})
+	for _, proto := range []string{"", "https"} {
+		req := httptest.NewRequest(http.MethodGet, "/secure/page", nil)
+		req.Header.Set("X-Forwarded-Proto", proto)
+		rec := httptest.NewRecorder()
+		hs.ServeHTTP(rec, req)
+		header := rec.Header()
+		if header.Get("X-Frame-Options") != "DENY" || header.Get("X-Content-Type-Options") != "nosniff" ||
+			header.Get("Content-Security-Policy") != "default-src 'self'" ||
+			header.Get("Referrer-Policy") != "strict-origin-when-cross-origin" || header.Get("Permissions-Policy") != "" {
+			t.Errorf("%q headers = %v", proto, header)
+		}
+		hsts := header.Get("Strict-Transport-Security")
+		if (proto == "https") != (hsts == "max-age=31536000; includeSubDomains") {
+			t.Errorf("%q hsts = %q", proto, hsts)
+		}
+	}
+}
@@ -15,22 +15,25 @@
 package grope
 
 import (
-	"encoding/json"
-	"github.com/aberic/gnomon/grope/tune"
+	"context"
 	"net/http"
-	"net/url"
+	"sort"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 // newGHttpServe 新建一个Http服务
 func newGHttpServe(filters ...Filter) *GHttpServe {
 	nodal := newNode(filters...)
-	return &GHttpServe{nodal: nodal}
+	return &GHttpServe{nodal: nodal, metrics: &metrics{}}
 }
 
 // GHttpServe Http服务
 type GHttpServe struct {
-	nodal *node
+	nodal       *node
+	contentType string   // 请求未指定内容类型时使用的内容类型
+	metrics     *metrics // 请求统计，通过 Metrics 输出
 }
 
 // Group 设置路由根路径
@@ -38,88 +41,82 @@ type GHttpServe struct {
 // pattern 路由根路径，如“/test”
 //
 // filters 待实现拦截器/过滤器方法数组
+//
+// 路由根路径非法（如“*filepath”不在末尾）时panic
 func (ghs *GHttpServe) Group(pattern string, filters ...Filter) *GHttpRouter {
-	ghs.nodal.add(pattern, "", nil, nil, nil, filters...)
+	if err := ghs.nodal.add(pattern, "", nil, nil, nil, filters...); nil != err {
+		panic(err)
+	}
 	ghr := &GHttpRouter{pattern: pattern, nodal: ghs.nodal}
 	return ghr
 }
 
+// Use 为服务追加过滤器/拦截器，对全部路由生效，在各路由分组的过滤器/拦截器之前执行
+func (ghs *GHttpServe) Use(filters ...Filter) {
+	ghs.nodal.lockNode.Lock()
+	ghs.nodal.filters = append(append([]Filter{}, ghs.nodal.filters...), filters...)
+	ghs.nodal.lockNode.Unlock()
+}
+
+// Routes 获取已注册的路由列表，按项目路径及请求方法排序，可用于诊断及测试
+func (ghs *GHttpServe) Routes() []*RouteInfo {
+	var routeInfos []*RouteInfo
+	ghs.nodal.walk(func(r *route) {
+		routeInfos = append(routeInfos, r.info())
+	})
+	sort.Slice(routeInfos, func(i, j int) bool {
+		if routeInfos[i].Pattern == routeInfos[j].Pattern {
+			return routeInfos[i].Method < routeInfos[j].Method
+		}
+		return routeInfos[i].Pattern < routeInfos[j].Pattern
+	})
+	return routeInfos
+}
+
 func (ghs *GHttpServe) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ghs.doServe(w, r)
 }
 
 // doMethod 处理请求具体方法
 func (ghs *GHttpServe) doServe(w http.ResponseWriter, r *http.Request) {
-	var ctx = &Context{writer: w, request: r, valueMap: map[string]string{}}
-	pattern, paramMap := ghs.parseURLParams(r)
-	ctx.paramMap = paramMap
-	n := ghs.nodal.fetch(pattern, r.Method)
-	if nil == n {
-		http.NotFound(w, r)
-		return
-	} else if nil != n.error {
-		w.Header().Set("Content-Type", tune.ContentTypeJSON)
-		w.WriteHeader(http.StatusOK)
-		bytes, _ := json.Marshal(n.error)
-		_, _ = w.Write(bytes)
+	recorder := &responseWriter{ResponseWriter: w}
+	var ctx = &Context{writer: recorder, request: r, valueMap: map[string]string{}, contentType: ghs.contentType, recorder: recorder}
+	pattern := ghs.singleSeparator(r.URL.Path)
+	rt := ghs.nodal.fetch(pattern, r.Method)
+	if nil == rt {
+		atomic.AddUint64(&ghs.metrics.unmatched, 1)
+		http.NotFound(recorder, r)
 		return
 	}
-	psURLReq := strings.Split(pattern, "/")[1:]
-	psURLLocal := strings.Split(n.pattern, "/")[1:]
-	for index, p := range psURLLocal {
-		if p[0] == ':' {
-			ctx.valueMap[p[1:]] = psURLReq[index]
-		}
-	}
-	ghs.execRoute(ctx, n)
-}
-
-// execRoute 处理请求逻辑
-func (ghs *GHttpServe) execRoute(ctx *Context, nodal *node) {
-	for _, filter := range nodal.filters { // 过滤无效请求
-		filter(ctx)
-		if ctx.responded {
+	mr := ghs.metrics.route(rt)
+	mr.begin()
+	start := time.Now()
+	defer func() { mr.end(recorder.status, time.Since(start)) }()
+	filters, extend := rt.chain()
+	if nil != extend {
+		if nil != extend.Limit && !extend.Limit.serve(ctx) {
+			mr.reject()
 			return
 		}
-	}
-	nodal.parseHandler(ctx)
-}
-
-func (ghs *GHttpServe) parseURLParams(r *http.Request) (pattern string, paramMap map[string]string) {
-	var (
-		// 项目路径，如“/demo/:id/:name”，与路由根路径相结合，最终会通过类似“http://127.0.0.1:8080/test/demo/1/g?name=hello&pass=work”方式进行访问
-		p  = ghs.singleSeparator(r.URL.String())
-		ps = strings.Split(p, "?")
-	)
-	if len(ps) != 2 {
-		pattern = p
-	} else {
-		paramMap = ghs.execURLParams(ps[1])
-		if len(paramMap) == 0 {
-			pattern = p
-		} else {
-			pattern = ps[0]
+		if extend.Timeout > 0 {
+			timeoutCtx, cancel := context.WithTimeout(r.Context(), extend.Timeout)
+			defer cancel()
+			ctx.request = r.WithContext(timeoutCtx)
 		}
 	}
-	return
+	rt.values(strings.Split(pattern, "/")[1:], ctx.valueMap)
+	ghs.execRoute(ctx, rt, filters)
 }
 
-func (ghs *GHttpServe) execURLParams(paramStr string) map[string]string {
-	paramMap := map[string]string{}
-	values, err := url.ParseQuery(paramStr)
-	if nil != err {
-		return paramMap
-	}
-	paramPair := strings.Split(paramStr, "&")
-	for _, pair := range paramPair {
-		valuePair := strings.Split(pair, "=")
-		if len(valuePair) != 1 {
-			paramMap[valuePair[0]] = values.Get(valuePair[0])
-		} else {
-			return map[string]string{}
-		}
-	}
-	return paramMap
+// execRoute 处理请求逻辑
+//
+// 调用链由过滤器/拦截器及请求处理方法组成，过滤器/拦截器未主动调用 Next 时，在其返回后继续执行调用链
+func (ghs *GHttpServe) execRoute(ctx *Context, rt *route, filters []Filter) {
+	ctx.handlers = make([]Filter, 0, len(filters)+1)
+	ctx.handlers = append(ctx.handlers, filters...)
+	ctx.handlers = append(ctx.handlers, rt.parseHandler)
+	ctx.index = -1
+	ctx.Next()
 }
 
 // singleSeparator 将字符串内所有连续/替换为单个/
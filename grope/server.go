@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// NewServer 新建一个可配置超时并支持优雅关闭的Http服务器
+//
+// Addr 期望监听的端口号，如“:8080”
+func NewServer(Addr string, gs *GHttpServe) *Server {
+	return &Server{Addr: Addr, Handler: gs}
+}
+
+// Server Http服务器，须在开始服务前完成配置
+type Server struct {
+	Addr              string                                      // 期望监听的端口号，如“:8080”
+	Handler           *GHttpServe                                 // Http服务
+	ReadTimeout       time.Duration                               // 读取整个请求（包括请求体）的超时时间，0表示不限
+	ReadHeaderTimeout time.Duration                               // 读取请求头的超时时间，0表示与 ReadTimeout 相同
+	WriteTimeout      time.Duration                               // 写入响应的超时时间，0表示不限
+	IdleTimeout       time.Duration                               // 长连接等待下一个请求的超时时间，0表示与 ReadTimeout 相同
+	MaxHeaderBytes    int                                         // 请求头最大字节数，0表示 http.DefaultMaxHeaderBytes
+	TLSConfig         *tls.Config                                 // 可选的TLS配置
+	BaseContext       func(listener net.Listener) context.Context // 所有请求上下文的基础上下文，可通过 Context.Request().Context() 获取
+
+	server *http.Server
+	once   sync.Once
+}
+
+// httpServer 根据配置创建原生 net/http 服务器
+func (s *Server) httpServer() *http.Server {
+	s.once.Do(func() {
+		s.server = &http.Server{
+			Addr:              s.Addr,
+			Handler:           s.Handler,
+			TLSConfig:         s.TLSConfig,
+			ReadTimeout:       s.ReadTimeout,
+			ReadHeaderTimeout: s.ReadHeaderTimeout,
+			WriteTimeout:      s.WriteTimeout,
+			IdleTimeout:       s.IdleTimeout,
+			MaxHeaderBytes:    s.MaxHeaderBytes,
+			BaseContext:       s.BaseContext,
+		}
+	})
+	return s.server
+}
+
+// ListenAndServe 启动监听并阻塞，调用 Shutdown 或 Close 后返回nil
+func (s *Server) ListenAndServe() error {
+	return serverErr(s.httpServer().ListenAndServe())
+}
+
+// ListenAndServeTLS 启动TLS监听并阻塞，调用 Shutdown 或 Close 后返回nil
+//
+// TLSConfig 中已包含证书时certFilePath及keyFilePath可为空
+func (s *Server) ListenAndServeTLS(certFilePath, keyFilePath string) error {
+	return serverErr(s.httpServer().ListenAndServeTLS(certFilePath, keyFilePath))
+}
+
+// Serve 在已有的监听上提供服务并阻塞，调用 Shutdown 或 Close 后返回nil
+//
+// listener 可为 tls.Listen 或 systemd socket activation 等提供的监听，服务结束时会被关闭
+func (s *Server) Serve(listener net.Listener) error {
+	return serverErr(s.httpServer().Serve(listener))
+}
+
+// Shutdown 优雅关闭服务器，停止接受新的请求并等待处理中的请求完成，ctx结束时返回 ctx.Err()
+//
+// 被劫持的连接（如websocket）不会被等待，需自行关闭
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer().Shutdown(ctx)
+}
+
+// Close 立即关闭服务器及所有连接
+func (s *Server) Close() error {
+	return s.httpServer().Close()
+}
+
+// RegisterOnShutdown 注册 Shutdown 时调用的方法，可用于通知websocket等长连接结束
+func (s *Server) RegisterOnShutdown(f func()) {
+	s.httpServer().RegisterOnShutdown(f)
+}
+
+// serverErr 服务器正常关闭时返回nil
+func serverErr(err error) error {
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
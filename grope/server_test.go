@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type serverContextKey struct{}
+
+func TestServerShutdown(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	hs := NewHTTPServe()
+	_ = hs.Group("/server").Get("/slow", func(ctx *Context) {
+		close(started)
+		<-release
+		_ = ctx.ResponseText(http.StatusOK, ctx.Request().Context().Value(serverContextKey{}).(string))
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	server := NewServer("", hs)
+	server.ReadHeaderTimeout = time.Second
+	server.BaseContext = func(net.Listener) context.Context {
+		return context.WithValue(context.Background(), serverContextKey{}, "base")
+	}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/server/slow")
+		if nil != err {
+			body <- err.Error()
+			return
+		}
+		defer func() { _ = resp.Body.Close() }()
+		bs, _ := ioutil.ReadAll(resp.Body)
+		body <- string(bs)
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- server.Shutdown(context.Background()) }()
+	if err = <-served; nil != err {
+		t.Fatalf("serve = %v", err)
+	}
+	select {
+	case err = <-shutdown:
+		t.Fatalf("shutdown returned before in-flight request finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, err = net.Dial("tcp", listener.Addr().String()); nil == err {
+		t.Fatal("listener still accepts connections")
+	}
+	close(release)
+	if got := <-body; got != "base" {
+		t.Fatalf("body = %s", got)
+	}
+	if err = <-shutdown; nil != err {
+		t.Fatalf("shutdown = %v", err)
+	}
+}
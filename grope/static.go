@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"fmt"
+	"github.com/aberic/gnomon"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// staticIndex 静态资源目录默认索引文件
+const staticIndex = "index.html"
+
+// Static 发起一个静态资源目录服务
+//
+// 支持目录索引文件“index.html”、“ETag”/“Last-Modified”缓存校验、“If-None-Match”及“Range”断点续传，并禁止访问目录之外的文件
+//
+// 与 http.FileServer 一致，不带末尾“/”的前缀路径如“/test/assets”将被重定向至“/test/assets/”
+//
+// prefix 项目路径前缀，如“/assets”，与路由根路径相结合，最终会通过类似“http://127.0.0.1:8080/test/assets/css/app.css”方式进行访问
+//
+// dir 静态资源目录，如“./web/dist”
+//
+// filters 待实现拦截器/过滤器方法数组
+func (ghr *GHttpRouter) Static(prefix, dir string, filters ...Filter) error {
+	root, err := filepath.Abs(dir)
+	if nil != err {
+		return err
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	if gnomon.StringIsNotEmpty(gnomon.StringBuild(ghr.pattern, prefix)) {
+		if err = ghr.Get(prefix, staticRedirect, filters...); nil != err {
+			return err
+		}
+	}
+	return ghr.Get(gnomon.StringBuild(prefix, "/*filepath"), func(ctx *Context) {
+		serveStatic(ctx, root, ctx.Value("filepath"))
+	}, filters...)
+}
+
+// staticRedirect 将不带末尾“/”的前缀路径重定向至以“/”结尾的目录路径，保留请求参数
+func staticRedirect(ctx *Context) {
+	ctx.responded = true
+	addr := gnomon.StringBuild(ctx.request.URL.EscapedPath(), "/")
+	if gnomon.StringIsNotEmpty(ctx.request.URL.RawQuery) {
+		addr = gnomon.StringBuild(addr, "?", ctx.request.URL.RawQuery)
+	}
+	_ = ctx.Redirect(http.StatusMovedPermanently, addr)
+}
+
+// serveStatic 在静态资源目录root中查找并返回name对应的文件
+func serveStatic(ctx *Context, root, name string) {
+	ctx.responded = true
+	filePath, ok := staticPath(root, name)
+	if !ok {
+		http.NotFound(ctx.writer, ctx.request)
+		return
+	}
+	fileInfo, err := os.Stat(filePath)
+	if nil == err && fileInfo.IsDir() {
+		filePath = filepath.Join(filePath, staticIndex)
+		fileInfo, err = os.Stat(filePath)
+	}
+	if nil != err || fileInfo.IsDir() {
+		http.NotFound(ctx.writer, ctx.request)
+		return
+	}
+	file, err := os.Open(filePath)
+	if nil != err {
+		http.NotFound(ctx.writer, ctx.request)
+		return
+	}
+	defer func() { _ = file.Close() }()
+	ctx.HeaderSet("ETag", fmt.Sprintf(`"%x-%x"`, fileInfo.ModTime().UnixNano(), fileInfo.Size()))
+	// ServeContent 负责处理 Last-Modified、If-None-Match、If-Modified-Since 及 Range
+	http.ServeContent(ctx.writer, ctx.request, fileInfo.Name(), fileInfo.ModTime(), file)
+}
+
+// staticPath 将请求路径转换为静态资源目录中的文件路径，路径超出目录时返回false
+func staticPath(root, name string) (string, bool) {
+	if strings.Contains(name, "\x00") || strings.Contains(name, "\\") {
+		return "", false
+	}
+	cleaned := path.Clean("/" + name) // 以根路径清理可消除所有“..”
+	filePath := filepath.Join(root, filepath.FromSlash(cleaned))
+	if filePath != root && !strings.HasPrefix(filePath, root+string(filepath.Separator)) {
+		return "", false
+	}
+	return filePath, true
+}
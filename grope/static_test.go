@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStatic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grope-static")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	_ = os.MkdirAll(filepath.Join(dir, "web", "css"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(dir, "web", "index.html"), []byte("<html>index</html>"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "web", "css", "app.css"), []byte("body{color:red}"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644)
+
+	hs := NewHTTPServe()
+	if err = hs.Group("/ui").Static("/", filepath.Join(dir, "web")); nil != err {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/css/app.css", nil))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || rec.Body.String() != "body{color:red}" || etag == "" || rec.Header().Get("Last-Modified") == "" {
+		t.Fatalf("css = %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui?v=1", nil))
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/ui/?v=1" {
+		t.Fatalf("prefix = %d %v", rec.Code, rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "<html>index</html>" {
+		t.Fatalf("index = %d %q", rec.Code, rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/ui/css/app.css", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	hs.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("If-None-Match = %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/ui/css/app.css", nil)
+	req.Header.Set("Range", "bytes=0-3")
+	rec = httptest.NewRecorder()
+	hs.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "body" {
+		t.Fatalf("Range = %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/css/../../secret.txt", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("traversal = %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestStaticPath(t *testing.T) {
+	root := filepath.FromSlash("/srv/web")
+	for name, ok := range map[string]bool{
+		"css/app.css":      true,
+		"":                 true,
+		"../secret.txt":    true, // 清理为“/secret.txt”，仍在目录之内
+		"..\\secret.txt":   false,
+		"css/\x00/app.css": false,
+	} {
+		filePath, got := staticPath(root, name)
+		if got != ok {
+			t.Errorf("%q = %s %v", name, filePath, got)
+		}
+		if got && !strings.HasPrefix(filePath, root) {
+			t.Errorf("%q escaped to %s", name, filePath)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"errors"
+	"github.com/aberic/gnomon"
+	"github.com/aberic/gnomon/grope/tune"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrStreamFlusher response writer does not implement http.Flusher
+	ErrStreamFlusher = errors.New("response writer does not implement http.Flusher")
+)
+
+// Stream 以分块传输的方式持续返回响应内容
+//
+// step 每次写入响应内容的方法，每次返回后立即刷新至客户端，返回false时结束
+//
+// return 客户端是否在结束前断开连接
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	flusher, ok := c.writer.(http.Flusher)
+	if !ok {
+		return false
+	}
+	c.responded = true
+	done := c.request.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+			keep := step(c.writer)
+			flusher.Flush()
+			if !keep {
+				return false
+			}
+		}
+	}
+}
+
+// LastEventID 返回客户端断线重连时携带的“Last-Event-ID”
+func (c *Context) LastEventID() string {
+	return c.requestHeader("Last-Event-ID")
+}
+
+// SSE 应答 Server-Sent Events 并返回事件发送器
+//
+// 自动设置“Content-Type: text/event-stream”等响应头部信息，并立即将 200 状态码刷新至客户端
+func (c *Context) SSE() (*SSEWriter, error) {
+	flusher, ok := c.writer.(http.Flusher)
+	if !ok {
+		return nil, ErrStreamFlusher
+	}
+	c.responded = true
+	c.HeaderSet("Content-Type", tune.ContentTypeEventStream)
+	c.HeaderSet("Cache-Control", "no-cache")
+	c.HeaderSet("X-Accel-Buffering", "no") // 禁止 nginx 等代理缓存响应
+	c.Status(http.StatusOK)
+	flusher.Flush()
+	return &SSEWriter{ctx: c, flusher: flusher}, nil
+}
+
+// SSEEvent Server-Sent Events 事件
+type SSEEvent struct {
+	ID    string        // 事件ID，客户端重连时通过“Last-Event-ID”回传
+	Event string        // 事件类型，为空时客户端以“message”处理
+	Retry time.Duration // 客户端断线重连间隔，0表示不设置
+	Data  string        // 事件内容，多行内容将按行拆分
+}
+
+// SSEWriter Server-Sent Events 事件发送器
+type SSEWriter struct {
+	ctx     *Context
+	flusher http.Flusher
+}
+
+// LastEventID 返回客户端断线重连时携带的“Last-Event-ID”
+func (s *SSEWriter) LastEventID() string {
+	return s.ctx.LastEventID()
+}
+
+// Done 客户端断开连接时关闭
+func (s *SSEWriter) Done() <-chan struct{} {
+	return s.ctx.request.Context().Done()
+}
+
+// Send 发送一个事件并立即刷新至客户端，客户端已断开连接时返回错误
+func (s *SSEWriter) Send(event *SSEEvent) error {
+	if err := s.ctx.request.Context().Err(); nil != err {
+		return err
+	}
+	var builder strings.Builder
+	if gnomon.StringIsNotEmpty(event.ID) {
+		builder.WriteString(gnomon.StringBuild("id: ", sseField(event.ID), "\n"))
+	}
+	if gnomon.StringIsNotEmpty(event.Event) {
+		builder.WriteString(gnomon.StringBuild("event: ", sseField(event.Event), "\n"))
+	}
+	if event.Retry > 0 {
+		builder.WriteString(gnomon.StringBuild("retry: ", strconv.FormatInt(int64(event.Retry/time.Millisecond), 10), "\n"))
+	}
+	for _, line := range strings.Split(sseLineBreaker.Replace(event.Data), "\n") {
+		builder.WriteString(gnomon.StringBuild("data: ", line, "\n"))
+	}
+	builder.WriteString("\n")
+	return s.write(builder.String())
+}
+
+// Comment 发送注释行，可作为心跳保持连接
+func (s *SSEWriter) Comment(text string) error {
+	if err := s.ctx.request.Context().Err(); nil != err {
+		return err
+	}
+	return s.write(gnomon.StringBuild(": ", sseField(text), "\n\n"))
+}
+
+func (s *SSEWriter) write(content string) error {
+	if _, err := io.WriteString(s.ctx.writer, content); nil != err {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// sseLineBreaker 将“\r\n”及单独的“\r”统一为“\n”，三者均为 SSE 的行结束符
+var sseLineBreaker = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// sseField 移除单行字段中的换行符，避免注入额外字段
+func sseField(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	hs := NewHTTPServe()
+	_ = hs.Group("/stream").Get("/chunks", func(ctx *Context) {
+		i := 0
+		ctx.Stream(func(w io.Writer) bool {
+			_, _ = fmt.Fprintf(w, "chunk%d;", i)
+			i++
+			return i < 3
+		})
+	})
+	rec := httptest.NewRecorder()
+	hs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream/chunks", nil))
+	if rec.Body.String() != "chunk0;chunk1;chunk2;" || !rec.Flushed {
+		t.Fatalf("body = %q flushed = %v", rec.Body.String(), rec.Flushed)
+	}
+}
+
+func TestSSE(t *testing.T) {
+	hs := NewHTTPServe()
+	_ = hs.Group("/stream").Get("/events", func(ctx *Context) {
+		sse, err := ctx.SSE()
+		if nil != err {
+			t.Fatal(err)
+		}
+		_ = sse.Send(&SSEEvent{ID: "8", Event: "progress", Retry: 3 * time.Second, Data: "line1\nline2"})
+		_ = sse.Comment("ping")
+		_ = sse.Send(&SSEEvent{Data: "resume from " + sse.LastEventID()})
+		_ = sse.Send(&SSEEvent{Data: "x\revent: evil\r\ny"})
+	})
+	req := httptest.NewRequest(http.MethodGet, "/stream/events", nil)
+	req.Header.Set("Last-Event-ID", "7")
+	rec := httptest.NewRecorder()
+	hs.ServeHTTP(rec, req)
+	expect := "id: 8\nevent: progress\nretry: 3000\ndata: line1\ndata: line2\n\n: ping\n\ndata: resume from 7\n\ndata: x\ndata: event: evil\ndata: y\n\n"
+	if rec.Header().Get("Content-Type") != "text/event-stream" || rec.Header().Get("Connection") != "" ||
+		rec.Body.String() != expect {
+		t.Fatalf("sse = %v %q", rec.Header(), rec.Body.String())
+	}
+}
+
+func TestSSEDisconnect(t *testing.T) {
+	hs := NewHTTPServe()
+	sent := make(chan error, 1)
+	_ = hs.Group("/stream").Get("/events", func(ctx *Context) {
+		sse, _ := ctx.SSE()
+		<-sse.Done()
+		sent <- sse.Send(&SSEEvent{Data: "late"})
+	})
+	c, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/stream/events", nil).WithContext(c)
+	cancel()
+	hs.ServeHTTP(httptest.NewRecorder(), req)
+	if err := <-sent; err != context.Canceled {
+		t.Fatalf("err = %v", err)
+	}
+}
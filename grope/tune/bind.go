@@ -0,0 +1,181 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tune
+
+import (
+	"github.com/aberic/gnomon"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTag 参数默认值标签，多个值以“,”分隔
+const DefaultTag = "default"
+
+// durationType time.Duration 类型
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// BindError 参数绑定失败信息
+type BindError struct {
+	Field string // 结构体参数名
+	Key   string // 标签中的参数名
+	Value string // 无法转换的参数值
+	Err   error  // 转换错误
+}
+
+func (be *BindError) Error() string {
+	return gnomon.StringBuild("bind ", be.Field, " from ", be.Key, "=", strconv.Quote(be.Value), ": ", be.Err.Error())
+}
+
+// BindValues 按参数标签将values中的值绑定至结构体obj
+//
+// tag 参数标签，如“query”对应“query:"page"”，未设置该标签或标签为“-”的参数将被忽略
+//
+// values 参数集合，同一参数可包含多个值，切片参数接收全部值且各值再以“,”分隔，如“?ids=1,2&ids=3”，其余参数接收首个值
+//
+// 参数不存在时使用“default”标签中的默认值，切片参数的默认值同样以“,”分隔；布尔参数值为空时视为true；支持字符串、整数、浮点数、布尔、time.Duration 及其切片和指针
+func BindValues(obj interface{}, tag string, values map[string][]string) error {
+	if err := CheckStruct(obj); nil != err {
+		return err
+	}
+	value := reflect.ValueOf(obj)
+	if value.Kind() != reflect.Ptr {
+		return ErrResponseObject
+	}
+	return bindStruct(value.Elem(), tag, values)
+}
+
+// bindStruct 绑定结构体参数，匿名嵌入的结构体参数同样会被绑定
+func bindStruct(value reflect.Value, tag string, values map[string][]string) error {
+	valueType := value.Type()
+	for index := 0; index < valueType.NumField(); index++ {
+		sf := valueType.Field(index)
+		field := value.Field(index)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if err := bindStruct(field, tag, values); nil != err {
+				return err
+			}
+			continue
+		}
+		key, ok := sf.Tag.Lookup(tag)
+		if !ok || key == "-" || !field.CanSet() {
+			continue
+		}
+		if comma := strings.Index(key, ","); comma >= 0 {
+			key = key[:comma]
+		}
+		if gnomon.StringIsEmpty(key) {
+			key = sf.Name
+		}
+		fieldValues, exist := values[key]
+		if !exist || len(fieldValues) == 0 {
+			def, hasDefault := sf.Tag.Lookup(DefaultTag)
+			if !hasDefault {
+				continue
+			}
+			fieldValues = []string{def}
+		}
+		if err := bindField(field, fieldValues); nil != err {
+			be := err.(*BindError)
+			be.Field, be.Key = sf.Name, key
+			return be
+		}
+	}
+	return nil
+}
+
+// bindField 将参数值绑定至单个参数
+func bindField(field reflect.Value, fieldValues []string) error {
+	switch field.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(field.Type().Elem())
+		if err := bindField(elem.Elem(), fieldValues); nil != err {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	case reflect.Slice:
+		items := bindSplit(fieldValues)
+		slice := reflect.MakeSlice(field.Type(), len(items), len(items))
+		for index, item := range items {
+			if err := bindScalar(slice.Index(index), item); nil != err {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	return bindScalar(field, fieldValues[0])
+}
+
+// bindSplit 将切片参数的各值以“,”分隔并忽略空值，与 Context.QuerySlice 一致
+func bindSplit(fieldValues []string) []string {
+	var items []string
+	for _, fieldValue := range fieldValues {
+		for _, item := range strings.Split(fieldValue, ",") {
+			if gnomon.StringIsNotEmpty(item) {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// bindScalar 将字符串转换为参数类型并赋值
+func bindScalar(field reflect.Value, fieldValue string) error {
+	var err error
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(fieldValue)
+	case reflect.Bool:
+		if gnomon.StringIsEmpty(fieldValue) { // 仅有参数名，如“?debug”
+			field.SetBool(true)
+			break
+		}
+		var b bool
+		if b, err = strconv.ParseBool(fieldValue); nil == err {
+			field.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if field.Type() == durationType {
+			var d time.Duration
+			d, err = time.ParseDuration(fieldValue)
+			i = int64(d)
+		} else {
+			i, err = strconv.ParseInt(fieldValue, 10, field.Type().Bits())
+		}
+		if nil == err {
+			field.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		if u, err = strconv.ParseUint(fieldValue, 10, field.Type().Bits()); nil == err {
+			field.SetUint(u)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(fieldValue, field.Type().Bits()); nil == err {
+			field.SetFloat(f)
+		}
+	default:
+		err = ErrResponseObject
+	}
+	if nil != err {
+		return &BindError{Value: fieldValue, Err: err}
+	}
+	return nil
+}
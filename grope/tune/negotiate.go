@@ -0,0 +1,188 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tune
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"github.com/golang/protobuf/proto"
+	"github.com/vmihailenco/msgpack"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// contentTypeAliases 常见的等价内容类型
+var contentTypeAliases = map[string]string{
+	"text/xml":                        ContentTypeXML,
+	"application/yaml":                ContentTypeYaml,
+	"text/yaml":                       ContentTypeYaml,
+	"text/x-yaml":                     ContentTypeYaml,
+	"application/msgpack":             ContentTypeMsgPack,
+	"application/protobuf":            ContentTypeProtoBuf,
+	"application/x-proto":             ContentTypeProtoBuf,
+	"application/vnd.google.protobuf": ContentTypeProtoBuf,
+}
+
+// MediaType 将“Content-Type”或“Accept”中的单个内容类型转换为标准形式，如“text/xml; charset=utf-8”转换为“application/xml”
+func MediaType(contentType string) string {
+	if index := strings.Index(contentType, ";"); index >= 0 {
+		contentType = contentType[:index]
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if alias, exist := contentTypeAliases[contentType]; exist {
+		return alias
+	}
+	return contentType
+}
+
+// accept “Accept”中的单个内容类型及其权重
+type accept struct {
+	mediaType string
+	q         float64
+}
+
+// specificity 内容类型的精确程度，“*/*”为0，“type/*”为1，其余为2
+func (a *accept) specificity() int {
+	if a.mediaType == "*/*" {
+		return 0
+	} else if strings.HasSuffix(a.mediaType, "/*") {
+		return 1
+	}
+	return 2
+}
+
+// match 内容类型是否与offer匹配
+func (a *accept) match(offer string) bool {
+	switch a.specificity() {
+	case 0:
+		return true
+	case 1:
+		return strings.HasPrefix(offer, strings.TrimSuffix(a.mediaType, "*"))
+	}
+	return a.mediaType == offer
+}
+
+// parseAccept 解析“Accept”，按权重由高到低排列，权重相同时更精确的内容类型优先
+func parseAccept(header string) []*accept {
+	var accepts []*accept
+	for _, part := range strings.Split(header, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		a := &accept{mediaType: MediaType(part), q: 1}
+		for _, param := range strings.Split(part, ";")[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); nil == err && q >= 0 && q <= 1 {
+					a.q = q
+				}
+			}
+		}
+		accepts = append(accepts, a)
+	}
+	sort.SliceStable(accepts, func(i, j int) bool {
+		if accepts[i].q != accepts[j].q {
+			return accepts[i].q > accepts[j].q
+		}
+		return accepts[i].specificity() > accepts[j].specificity()
+	})
+	return accepts
+}
+
+// Negotiate 根据“Accept”在offers中选择客户端最期望的内容类型
+//
+// “Accept”为空时返回defaultOffer；“*/*”等通配类型优先匹配defaultOffer；无可接受的内容类型时返回空字符串
+//
+// offers 服务端支持的内容类型，按服务端优先级排列
+func Negotiate(header string, offers []string, defaultOffer string) string {
+	if strings.TrimSpace(header) == "" {
+		return defaultOffer
+	}
+	accepts := parseAccept(header)
+	excluded := map[string]bool{}
+	for _, a := range accepts {
+		if a.q == 0 && a.specificity() == 2 {
+			excluded[a.mediaType] = true
+		}
+	}
+	for _, a := range accepts {
+		if a.q == 0 {
+			continue
+		}
+		if a.specificity() < 2 && a.match(defaultOffer) && !excluded[defaultOffer] {
+			return defaultOffer
+		}
+		for _, offer := range offers {
+			if a.match(offer) && !excluded[offer] {
+				return offer
+			}
+		}
+	}
+	return ""
+}
+
+// Decode 按内容类型解析数据流至obj，不支持的内容类型返回 ErrContentType
+//
+// contentType 内容类型，“application/x-protobuf”要求obj实现 proto.Message
+func Decode(r io.Reader, contentType string, obj interface{}) error {
+	switch MediaType(contentType) {
+	case ContentTypeJSON:
+		return json.NewDecoder(r).Decode(obj)
+	case ContentTypeXML:
+		return xml.NewDecoder(r).Decode(obj)
+	case ContentTypeYaml:
+		return yaml.NewDecoder(r).Decode(obj)
+	case ContentTypeMsgPack:
+		return msgpack.NewDecoder(r).Decode(obj)
+	case ContentTypeProtoBuf:
+		pm, ok := obj.(proto.Message)
+		if !ok {
+			return ErrContentType
+		}
+		bs, err := ioutil.ReadAll(r)
+		if nil != err {
+			return err
+		}
+		return proto.UnmarshalMerge(bs, pm)
+	}
+	return ErrContentType
+}
+
+// Encode 按内容类型序列化obj，不支持的内容类型返回 ErrContentType
+//
+// contentType 内容类型，“application/x-protobuf”要求obj实现 proto.Message
+func Encode(contentType string, obj interface{}) ([]byte, error) {
+	switch MediaType(contentType) {
+	case ContentTypeJSON:
+		return json.Marshal(obj)
+	case ContentTypeXML:
+		return xml.Marshal(obj)
+	case ContentTypeYaml:
+		return yaml.Marshal(obj)
+	case ContentTypeMsgPack:
+		return msgpack.Marshal(obj)
+	case ContentTypeProtoBuf:
+		pm, ok := obj.(proto.Message)
+		if !ok {
+			return nil, ErrContentType
+		}
+		return proto.Marshal(pm)
+	}
+	return nil, ErrContentType
+}
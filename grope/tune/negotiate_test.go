@@ -0,0 +1,39 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tune
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{ContentTypeJSON, ContentTypeXML}
+	cases := []struct {
+		accept       string
+		defaultOffer string
+		expect       string
+	}{
+		{"", ContentTypeJSON, ContentTypeJSON},
+		{"*/*", ContentTypeXML, ContentTypeXML},
+		{"application/xml;q=0.5, application/json", ContentTypeXML, ContentTypeJSON},
+		{"*/*, application/json;q=0", ContentTypeJSON, ContentTypeXML},
+		{"application/*, application/xml;q=0", ContentTypeXML, ContentTypeJSON},
+		{"application/json;q=0, application/xml;q=0, */*", ContentTypeJSON, ""},
+		{"text/html", ContentTypeJSON, ""},
+	}
+	for _, c := range cases {
+		if offer := Negotiate(c.accept, offers, c.defaultOffer); offer != c.expect {
+			t.Errorf("Negotiate(%q, %q) = %q, expect %q", c.accept, c.defaultOffer, offer, c.expect)
+		}
+	}
+}
@@ -44,6 +44,8 @@ const (
 	ContentTypeMsgPack = "application/x-msgpack"
 	// ContentTypeProtoBuf application/x-protobuf
 	ContentTypeProtoBuf = "application/x-protobuf"
+	// ContentTypeEventStream text/event-stream
+	ContentTypeEventStream = "text/event-stream"
 	//ContentTypeHtml              = "text/html"
 	//ContentTypeXml2              = "text/xml"
 	//ContentTypeMsgPack2          = "application/msgpack"
@@ -58,7 +60,7 @@ var (
 func ParseJSON(r *http.Request, obj interface{}) error {
 	contentType := r.Header.Get("Content-Type")
 	if strings.Contains(contentType, ContentTypeJSON) {
-		if err := ValidateStruct(obj); nil != err {
+		if err := CheckStruct(obj); nil != err {
 			return err
 		}
 		return json.NewDecoder(r.Body).Decode(obj)
@@ -70,7 +72,7 @@ func ParseJSON(r *http.Request, obj interface{}) error {
 func ParseXML(r *http.Request, obj interface{}) error {
 	contentType := r.Header.Get("Content-Type")
 	if strings.Contains(contentType, ContentTypeXML) {
-		if err := ValidateStruct(obj); nil != err {
+		if err := CheckStruct(obj); nil != err {
 			return err
 		}
 		return xml.NewDecoder(r.Body).Decode(obj)
@@ -82,7 +84,7 @@ func ParseXML(r *http.Request, obj interface{}) error {
 func ParseYaml(r *http.Request, obj interface{}) error {
 	contentType := r.Header.Get("Content-Type")
 	if strings.Contains(contentType, ContentTypeYaml) {
-		if err := ValidateStruct(obj); nil != err {
+		if err := CheckStruct(obj); nil != err {
 			return err
 		}
 		return yaml.NewDecoder(r.Body).Decode(obj)
@@ -94,7 +96,7 @@ func ParseYaml(r *http.Request, obj interface{}) error {
 func ParseMsgPack(r *http.Request, obj interface{}) error {
 	contentType := r.Header.Get("Content-Type")
 	if strings.Contains(contentType, ContentTypeMsgPack) {
-		if err := ValidateStruct(obj); nil != err {
+		if err := CheckStruct(obj); nil != err {
 			return err
 		}
 		return msgpack.NewDecoder(r.Body).Decode(obj)
@@ -16,16 +16,57 @@ package tune
 
 import (
 	"errors"
+	"fmt"
+	"github.com/aberic/gnomon"
+	"net/mail"
 	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
+// ValidateTag 结构体校验规则标签
+const ValidateTag = "validate"
+
 var (
 	// ErrResponseObject valid error when parse object
 	ErrResponseObject = errors.New("valid error when parse object")
 )
 
-// ValidateStruct 验证数据结构体与否
-func ValidateStruct(obj interface{}) error {
+var (
+	// validateRegexps 已编译的“regexp”规则
+	validateRegexps sync.Map
+	// validateTypes 各类型是否包含校验规则，key为 reflect.Type，value为bool
+	validateTypes sync.Map
+)
+
+// FieldError 单个参数校验失败信息
+type FieldError struct {
+	Field   string // 参数路径，如“Address.City”或“Items[0].Name”
+	Rule    string // 校验失败的规则，如“required”或“min”
+	Param   string // 规则参数，如“min=1”中的“1”
+	Message string // 错误描述
+}
+
+func (fe *FieldError) Error() string {
+	return gnomon.StringBuild(fe.Field, " ", fe.Message)
+}
+
+// ValidationErrors 结构体校验失败的参数集合
+type ValidationErrors []*FieldError
+
+func (ve ValidationErrors) Error() string {
+	messages := make([]string, len(ve))
+	for index, fe := range ve {
+		messages[index] = fe.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// CheckStruct 验证数据是否为结构体或结构体指针
+func CheckStruct(obj interface{}) error {
 	value := reflect.ValueOf(obj)
 	valueType := value.Kind()
 	if valueType == reflect.Ptr {
@@ -39,3 +80,263 @@ func ValidateStruct(obj interface{}) error {
 	}
 	return ErrResponseObject
 }
+
+// ValidateStruct 验证数据结构体，并按参数标签“validate”中的规则逐一校验，规则间以“,”分隔，嵌套结构体及切片中的结构体同样会被校验，
+// 匿名嵌入的结构体参数视为外层参数
+//
+// required 不能为零值，非必填参数为零值时跳过其余规则
+//
+// min=1/max=64 字符串为字符数，切片及map为元素数，数字为数值
+//
+// email 有效的邮箱地址
+//
+// oneof=a b 取值为以空格分隔的候选值之一
+//
+// regexp=^[a-z]+$ 匹配正则表达式，须作为最后一条规则，其后的“,”将作为表达式的一部分
+//
+// “validate:"-"”表示跳过该参数及其嵌套参数
+//
+// 校验不通过时返回 ValidationErrors
+func ValidateStruct(obj interface{}) error {
+	if err := CheckStruct(obj); nil != err {
+		return err
+	}
+	value := indirect(reflect.ValueOf(obj))
+	if !validateNeeded(value.Type()) {
+		return nil
+	}
+	if errs := validateStruct("", value, nil); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateStruct 校验结构体参数，匿名嵌入的结构体参数与 BindValues 一致视为外层参数
+func validateStruct(prefix string, value reflect.Value, errs ValidationErrors) ValidationErrors {
+	valueType := value.Type()
+	for index := 0; index < valueType.NumField(); index++ {
+		sf := valueType.Field(index)
+		tag := sf.Tag.Get(ValidateTag)
+		if tag == "-" {
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			errs = validateStruct(prefix, value.Field(index), errs)
+			continue
+		}
+		if gnomon.StringIsNotEmpty(sf.PkgPath) { // 未导出参数
+			continue
+		}
+		path := sf.Name
+		if gnomon.StringIsNotEmpty(prefix) {
+			path = gnomon.StringBuild(prefix, ".", sf.Name)
+		}
+		errs = validateValue(path, tag, value.Field(index), errs)
+	}
+	return errs
+}
+
+// validateValue 校验参数，并校验其中包含规则的结构体及结构体切片
+func validateValue(path, tag string, value reflect.Value, errs ValidationErrors) ValidationErrors {
+	value = indirect(value)
+	if gnomon.StringIsNotEmpty(tag) {
+		errs = validateRules(path, tag, value, errs)
+	}
+	if !value.IsValid() {
+		return errs
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		if validateNeeded(value.Type()) {
+			errs = validateStruct(path, value, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		if validateNeeded(value.Type().Elem()) {
+			for index := 0; index < value.Len(); index++ {
+				errs = validateValue(gnomon.StringBuild(path, "[", strconv.Itoa(index), "]"), "", value.Index(index), errs)
+			}
+		}
+	}
+	return errs
+}
+
+// validateNeeded 类型中是否包含校验规则，结果按类型缓存，不包含规则的结构体及非结构体元素的切片（如[]byte）无需逐一遍历
+func validateNeeded(valueType reflect.Type) bool {
+	if needed, ok := validateTypes.Load(valueType); ok {
+		return needed.(bool)
+	}
+	needed := validateTypeNeeded(valueType, map[reflect.Type]bool{})
+	validateTypes.Store(valueType, needed)
+	return needed
+}
+
+// validateTypeNeeded 递归判断类型中是否包含校验规则，visiting为正在判断的类型，用于避免自引用类型无限递归
+func validateTypeNeeded(valueType reflect.Type, visiting map[reflect.Type]bool) bool {
+	for valueType.Kind() == reflect.Ptr || valueType.Kind() == reflect.Slice || valueType.Kind() == reflect.Array {
+		valueType = valueType.Elem()
+	}
+	switch valueType.Kind() {
+	case reflect.Interface: // 实际类型未知
+		return true
+	case reflect.Struct:
+	default:
+		return false
+	}
+	if visiting[valueType] {
+		return false
+	}
+	visiting[valueType] = true
+	for index := 0; index < valueType.NumField(); index++ {
+		sf := valueType.Field(index)
+		tag := sf.Tag.Get(ValidateTag)
+		if tag == "-" {
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if validateTypeNeeded(sf.Type, visiting) {
+				return true
+			}
+			continue
+		}
+		if gnomon.StringIsNotEmpty(sf.PkgPath) {
+			continue
+		}
+		if gnomon.StringIsNotEmpty(tag) || validateTypeNeeded(sf.Type, visiting) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRules 按规则标签校验参数值
+func validateRules(path, tag string, value reflect.Value, errs ValidationErrors) ValidationErrors {
+	zero := !value.IsValid() || value.IsZero()
+	for _, rule := range splitRules(tag) {
+		name, param := rule, ""
+		if index := strings.Index(rule, "="); index >= 0 {
+			name, param = rule[:index], rule[index+1:]
+		}
+		if name == "required" {
+			if zero {
+				return append(errs, &FieldError{Field: path, Rule: name, Message: "is required"})
+			}
+			continue
+		}
+		if zero {
+			return errs
+		}
+		if message := validateRule(name, param, value); gnomon.StringIsNotEmpty(message) {
+			errs = append(errs, &FieldError{Field: path, Rule: name, Param: param, Message: message})
+		}
+	}
+	return errs
+}
+
+// validateRule 校验单条规则，通过时返回空字符串
+func validateRule(name, param string, value reflect.Value) string {
+	switch name {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+		if nil != err {
+			return gnomon.StringBuild("has invalid rule ", name, "=", param)
+		}
+		size, ok := validateSize(value)
+		if !ok {
+			return gnomon.StringBuild("does not support rule ", name)
+		}
+		if name == "min" && size < limit {
+			return gnomon.StringBuild("must be at least ", param)
+		} else if name == "max" && size > limit {
+			return gnomon.StringBuild("must be at most ", param)
+		}
+	case "email":
+		if value.Kind() != reflect.String {
+			return gnomon.StringBuild("does not support rule ", name)
+		}
+		if address, err := mail.ParseAddress(value.String()); nil != err || address.Address != value.String() {
+			return "must be a valid email address"
+		}
+	case "oneof":
+		current := fmt.Sprint(value)
+		for _, option := range strings.Fields(param) {
+			if option == current {
+				return ""
+			}
+		}
+		return gnomon.StringBuild("must be one of [", param, "]")
+	case "regexp":
+		if value.Kind() != reflect.String {
+			return gnomon.StringBuild("does not support rule ", name)
+		}
+		reg, err := validateRegexp(param)
+		if nil != err {
+			return gnomon.StringBuild("has invalid rule ", name, "=", param)
+		}
+		if !reg.MatchString(value.String()) {
+			return gnomon.StringBuild("must match ", param)
+		}
+	default:
+		return gnomon.StringBuild("has unknown rule ", name)
+	}
+	return ""
+}
+
+// validateSize 获取参数用于“min”及“max”比较的大小
+func validateSize(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	}
+	return 0, false
+}
+
+// validateRegexp 获取已编译的正则表达式
+func validateRegexp(pattern string) (*regexp.Regexp, error) {
+	if reg, ok := validateRegexps.Load(pattern); ok {
+		return reg.(*regexp.Regexp), nil
+	}
+	reg, err := regexp.Compile(pattern)
+	if nil != err {
+		return nil, err
+	}
+	validateRegexps.Store(pattern, reg)
+	return reg, nil
+}
+
+// splitRules 拆分规则标签，“regexp”之后的内容整体作为正则表达式
+func splitRules(tag string) []string {
+	var rules []string
+	for gnomon.StringIsNotEmpty(tag) {
+		if strings.HasPrefix(tag, "regexp=") {
+			return append(rules, tag)
+		}
+		index := strings.Index(tag, ",")
+		if index < 0 {
+			return append(rules, strings.TrimSpace(tag))
+		}
+		if rule := strings.TrimSpace(tag[:index]); gnomon.StringIsNotEmpty(rule) {
+			rules = append(rules, rule)
+		}
+		tag = strings.TrimSpace(tag[index+1:])
+	}
+	return rules
+}
+
+// indirect 解析指针类型为标准类型，空指针返回无效值
+func indirect(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+	return value
+}
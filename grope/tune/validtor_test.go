@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tune
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type validateAddress struct {
+	City string `validate:"required"`
+}
+
+type validateItem struct {
+	Name string `validate:"required,max=4"`
+}
+
+type validateBase struct {
+	ID   int    `query:"id" validate:"min=1"`
+	Kind string `query:"kind" validate:"oneof=a b"`
+}
+
+type validateUser struct {
+	validateBase
+	Name    string           `validate:"required,min=2,max=8"`
+	Email   string           `validate:"email"`
+	Code    string           `validate:"regexp=^[a-z]{2,3}$"`
+	Address *validateAddress `json:"address"`
+	Items   []*validateItem  `validate:"max=2"`
+	Skip    *validateAddress `validate:"-"`
+	Data    []byte
+	Next    *validateUser
+}
+
+type validateNone struct {
+	Name string `json:"name"`
+	Data []byte
+	Tags []string
+	Next *validateNone
+}
+
+func validateFields(err error) string {
+	errs, _ := err.(ValidationErrors)
+	var fields []string
+	for _, fe := range errs {
+		fields = append(fields, fe.Field+":"+fe.Rule)
+	}
+	return strings.Join(fields, " ")
+}
+
+func TestValidateStruct(t *testing.T) {
+	cases := []struct {
+		user   *validateUser
+		fields string
+	}{
+		{&validateUser{Name: "aberic", Email: "a@b.com", Code: "ab", Address: &validateAddress{City: "x"},
+			Items: []*validateItem{{Name: "a"}}, Skip: &validateAddress{}, Data: make([]byte, 1<<20)}, ""},
+		{&validateUser{}, "Name:required"},
+		{&validateUser{validateBase: validateBase{ID: -1, Kind: "c"}, Name: "a", Email: "a@", Code: "abcd"},
+			"ID:min Kind:oneof Name:min Email:email Code:regexp"},
+		{&validateUser{Name: "aberic", Address: &validateAddress{},
+			Items: []*validateItem{{Name: "ok"}, {Name: "toolong"}, {}}}, "Address.City:required Items:max Items[1].Name:max Items[2].Name:required"},
+		{&validateUser{Name: "aberic", Next: &validateUser{Name: "a"}}, "Next.Name:min"},
+	}
+	for index, c := range cases {
+		if fields := validateFields(ValidateStruct(c.user)); fields != c.fields {
+			t.Errorf("case %d fields = %s, expect %s", index, fields, c.fields)
+		}
+	}
+	if fields := validateFields(ValidateStruct(validateUser{})); fields != "Name:required" {
+		t.Errorf("struct value fields = %s", fields)
+	}
+	if err := ValidateStruct("text"); err != ErrResponseObject {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestValidateNeeded(t *testing.T) {
+	cases := map[reflect.Type]bool{
+		reflect.TypeOf(validateUser{}):    true,
+		reflect.TypeOf(&validateBase{}):   true,
+		reflect.TypeOf([]*validateItem{}): true,
+		reflect.TypeOf(validateNone{}):    false,
+		reflect.TypeOf([]byte{}):          false,
+	}
+	for valueType, expect := range cases {
+		if needed := validateNeeded(valueType); needed != expect {
+			t.Errorf("%v needed = %v", valueType, needed)
+		}
+	}
+}
+
+// TestValidateEmbedded 匿名嵌入结构体的参数与 BindValues 一致视为外层参数
+func TestValidateEmbedded(t *testing.T) {
+	user := &validateUser{Name: "aberic"}
+	if err := BindValues(user, "query", map[string][]string{"id": {"0"}, "kind": {"c"}}); nil != err {
+		t.Fatal(err)
+	}
+	if fields := validateFields(ValidateStruct(user)); fields != "Kind:oneof" {
+		t.Errorf("fields = %s", fields)
+	}
+}
@@ -0,0 +1,530 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/aberic/gnomon"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// WebsocketTextMessage 文本消息，内容为UTF-8编码
+	WebsocketTextMessage = 1
+	// WebsocketBinaryMessage 二进制消息
+	WebsocketBinaryMessage = 2
+	// WebsocketCloseMessage 关闭控制帧
+	WebsocketCloseMessage = 8
+	// WebsocketPingMessage ping控制帧
+	WebsocketPingMessage = 9
+	// WebsocketPongMessage pong控制帧
+	WebsocketPongMessage = 10
+)
+
+const (
+	// WebsocketCloseNormalClosure 正常关闭
+	WebsocketCloseNormalClosure = 1000
+	// WebsocketCloseGoingAway 终端离开，如服务关闭或浏览器跳转
+	WebsocketCloseGoingAway = 1001
+	// WebsocketCloseProtocolError 协议错误
+	WebsocketCloseProtocolError = 1002
+	// WebsocketCloseUnsupportedData 不支持的数据类型
+	WebsocketCloseUnsupportedData = 1003
+	// WebsocketCloseNoStatusReceived 未收到状态码，不可在关闭帧中发送
+	WebsocketCloseNoStatusReceived = 1005
+	// WebsocketCloseInvalidPayload 消息内容与类型不符，如文本消息非UTF-8编码
+	WebsocketCloseInvalidPayload = 1007
+	// WebsocketCloseMessageTooBig 消息超出大小限制
+	WebsocketCloseMessageTooBig = 1009
+	// WebsocketCloseInternalServerErr 服务端内部错误
+	WebsocketCloseInternalServerErr = 1011
+)
+
+const (
+	// websocketGUID 用于生成“Sec-WebSocket-Accept”的固定GUID
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// websocketMaxControlPayload 控制帧最大负载字节数
+	websocketMaxControlPayload = 125
+	// websocketDefaultReadLimit 默认单条消息最大字节数
+	websocketDefaultReadLimit = 32 << 20
+
+	websocketFinalBit = 0x80
+	websocketRsvBits  = 0x70
+	websocketMaskBit  = 0x80
+
+	websocketContinuationFrame = 0
+)
+
+var (
+	// ErrWebsocketHandshake websocket handshake error
+	ErrWebsocketHandshake = errors.New("websocket handshake error")
+	// ErrWebsocketOrigin websocket origin not allowed
+	ErrWebsocketOrigin = errors.New("websocket origin not allowed")
+	// ErrWebsocketHijack websocket response writer does not implement http.Hijacker
+	ErrWebsocketHijack = errors.New("websocket response writer does not implement http.Hijacker")
+	// ErrWebsocketMessageTooBig websocket message exceeds read limit
+	ErrWebsocketMessageTooBig = errors.New("websocket message exceeds read limit")
+	// ErrWebsocketClosed websocket connection closed
+	ErrWebsocketClosed = errors.New("websocket connection closed")
+	// ErrWebsocketMessageType websocket message type error
+	ErrWebsocketMessageType = errors.New("websocket message type error")
+)
+
+// WebsocketCloseError 对方发送关闭帧或因协议错误关闭连接时返回的错误
+type WebsocketCloseError struct {
+	Code int    // 关闭状态码，eg:WebsocketCloseNormalClosure
+	Text string // 关闭原因
+}
+
+func (e *WebsocketCloseError) Error() string {
+	return fmt.Sprintf("websocket closed: %d %s", e.Code, e.Text)
+}
+
+// WebsocketConfig websocket 握手及连接配置
+type WebsocketConfig struct {
+	// 单条消息(含分片)最大字节数，超出时以1009关闭连接，默认32MB
+	ReadLimit int64
+	// 发送数据消息时每个分片的最大字节数，0表示不分片
+	WriteFragmentSize int
+	// 服务端支持的子协议，按优先级排列
+	Subprotocols []string
+	// 校验请求来源，nil表示仅允许未携带“Origin”或与请求Host一致的来源
+	CheckOrigin func(r *http.Request) bool
+}
+
+// UpgradeWebsocket 完成 websocket 握手并返回连接，握手失败时已应答客户端对应的错误状态码
+//
+// config 握手及连接配置，nil表示使用默认配置
+func (c *Context) UpgradeWebsocket(config *WebsocketConfig) (*WebsocketConn, error) {
+	if nil == config {
+		config = &WebsocketConfig{}
+	}
+	if c.request.Method != http.MethodGet || !c.IsWebsocket() || c.requestHeader("Sec-WebSocket-Version") != "13" {
+		c.websocketError(http.StatusBadRequest)
+		return nil, ErrWebsocketHandshake
+	}
+	key := c.requestHeader("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); nil != err || len(decoded) != 16 {
+		c.websocketError(http.StatusBadRequest)
+		return nil, ErrWebsocketHandshake
+	}
+	checkOrigin := config.CheckOrigin
+	if nil == checkOrigin {
+		checkOrigin = websocketSameOrigin
+	}
+	if !checkOrigin(c.request) {
+		c.websocketError(http.StatusForbidden)
+		return nil, ErrWebsocketOrigin
+	}
+	hijacker, ok := c.writer.(http.Hijacker)
+	if !ok {
+		c.websocketError(http.StatusInternalServerError)
+		return nil, ErrWebsocketHijack
+	}
+	subprotocol := websocketSubprotocol(c.request, config.Subprotocols)
+	conn, brw, err := hijacker.Hijack()
+	if nil != err {
+		return nil, err
+	}
+	c.responded = true
+	handshake := gnomon.StringBuild(
+		"HTTP/1.1 101 Switching Protocols\r\n",
+		"Upgrade: websocket\r\n",
+		"Connection: Upgrade\r\n",
+		"Sec-WebSocket-Accept: ", websocketAccept(key), "\r\n")
+	if gnomon.StringIsNotEmpty(subprotocol) {
+		handshake = gnomon.StringBuild(handshake, "Sec-WebSocket-Protocol: ", subprotocol, "\r\n")
+	}
+	handshake = gnomon.StringBuild(handshake, "\r\n")
+	if _, err = brw.WriteString(handshake); nil == err {
+		err = brw.Flush()
+	}
+	if nil != err {
+		_ = conn.Close()
+		return nil, err
+	}
+	readLimit := config.ReadLimit
+	if readLimit <= 0 {
+		readLimit = websocketDefaultReadLimit
+	}
+	return &WebsocketConn{
+		conn:         conn,
+		reader:       brw.Reader,
+		readLimit:    readLimit,
+		fragmentSize: config.WriteFragmentSize,
+		subprotocol:  subprotocol,
+	}, nil
+}
+
+func (c *Context) websocketError(statusCode int) {
+	c.responded = true
+	c.HeaderSet("Sec-WebSocket-Version", "13")
+	http.Error(c.writer, http.StatusText(statusCode), statusCode)
+}
+
+// websocketAccept 根据“Sec-WebSocket-Key”生成“Sec-WebSocket-Accept”
+func websocketAccept(key string) string {
+	h := sha1.New()
+	_, _ = h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// websocketSameOrigin 仅允许未携带“Origin”或与请求Host一致的来源
+func websocketSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if gnomon.StringIsEmpty(origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if nil != err {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// websocketSubprotocol 按服务端优先级选择客户端支持的子协议
+func websocketSubprotocol(r *http.Request, subprotocols []string) string {
+	var clientProtocols []string
+	for _, value := range r.Header["Sec-Websocket-Protocol"] {
+		for _, protocol := range strings.Split(value, ",") {
+			clientProtocols = append(clientProtocols, strings.TrimSpace(protocol))
+		}
+	}
+	for _, protocol := range subprotocols {
+		for _, clientProtocol := range clientProtocols {
+			if protocol == clientProtocol {
+				return protocol
+			}
+		}
+	}
+	return ""
+}
+
+// WebsocketConn websocket 连接
+//
+// 同一时刻仅允许一个协程读取，写入方法可由多个协程并发调用
+type WebsocketConn struct {
+	conn         net.Conn
+	reader       *bufio.Reader
+	readLimit    int64
+	fragmentSize int
+	subprotocol  string
+	pingHandler  func(data []byte) error
+	pongHandler  func(data []byte) error
+	writeLock    sync.Mutex
+	closeSent    bool
+}
+
+// Subprotocol 握手时协商的子协议
+func (wc *WebsocketConn) Subprotocol() string {
+	return wc.subprotocol
+}
+
+// RemoteAddr 对方网络地址
+func (wc *WebsocketConn) RemoteAddr() net.Addr {
+	return wc.conn.RemoteAddr()
+}
+
+// SetReadLimit 设置单条消息(含分片)最大字节数
+func (wc *WebsocketConn) SetReadLimit(limit int64) {
+	wc.readLimit = limit
+}
+
+// SetReadDeadline 设置读取超时时间，零值表示不超时
+func (wc *WebsocketConn) SetReadDeadline(t time.Time) error {
+	return wc.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline 设置写入超时时间，零值表示不超时
+func (wc *WebsocketConn) SetWriteDeadline(t time.Time) error {
+	return wc.conn.SetWriteDeadline(t)
+}
+
+// SetPingHandler 设置收到ping控制帧时的处理方法，默认回复相同内容的pong控制帧
+func (wc *WebsocketConn) SetPingHandler(handler func(data []byte) error) {
+	wc.pingHandler = handler
+}
+
+// SetPongHandler 设置收到pong控制帧时的处理方法，默认忽略
+func (wc *WebsocketConn) SetPongHandler(handler func(data []byte) error) {
+	wc.pongHandler = handler
+}
+
+// ReadMessage 读取一条完整的数据消息，分片消息将被合并，期间收到的控制帧会被自动处理
+//
+// 对方关闭连接时返回 *WebsocketCloseError
+func (wc *WebsocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	for {
+		var (
+			final   bool
+			opcode  int
+			payload []byte
+		)
+		if final, opcode, payload, err = wc.readFrame(int64(len(data))); nil != err {
+			return 0, nil, err
+		}
+		switch opcode {
+		case WebsocketCloseMessage:
+			return 0, nil, wc.handleClose(payload)
+		case WebsocketPingMessage:
+			if err = wc.handlePing(payload); nil != err {
+				return 0, nil, err
+			}
+			continue
+		case WebsocketPongMessage:
+			if nil != wc.pongHandler {
+				if err = wc.pongHandler(payload); nil != err {
+					return 0, nil, err
+				}
+			}
+			continue
+		case websocketContinuationFrame:
+			if messageType == 0 {
+				return 0, nil, wc.fail(WebsocketCloseProtocolError, "unexpected continuation frame")
+			}
+		case WebsocketTextMessage, WebsocketBinaryMessage:
+			if messageType != 0 {
+				return 0, nil, wc.fail(WebsocketCloseProtocolError, "expected continuation frame")
+			}
+			messageType = opcode
+		default:
+			return 0, nil, wc.fail(WebsocketCloseProtocolError, "unknown opcode")
+		}
+		data = append(data, payload...)
+		if final {
+			if messageType == WebsocketTextMessage && !utf8.Valid(data) {
+				return 0, nil, wc.fail(WebsocketCloseInvalidPayload, "invalid utf8 payload")
+			}
+			return messageType, data, nil
+		}
+	}
+}
+
+// readFrame 读取一个帧，read为当前消息已读取的字节数，用于校验消息大小
+func (wc *WebsocketConn) readFrame(read int64) (final bool, opcode int, payload []byte, err error) {
+	header := make([]byte, 2)
+	if _, err = io.ReadFull(wc.reader, header); nil != err {
+		return
+	}
+	final = header[0]&websocketFinalBit != 0
+	opcode = int(header[0] & 0x0f)
+	if header[0]&websocketRsvBits != 0 {
+		err = wc.fail(WebsocketCloseProtocolError, "unexpected reserved bits")
+		return
+	}
+	if header[1]&websocketMaskBit == 0 {
+		err = wc.fail(WebsocketCloseProtocolError, "client frame must be masked")
+		return
+	}
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err = io.ReadFull(wc.reader, extended); nil != err {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err = io.ReadFull(wc.reader, extended); nil != err {
+			return
+		}
+		// RFC 6455 要求64位长度的最高位为0
+		value := binary.BigEndian.Uint64(extended)
+		if value>>63 != 0 {
+			err = wc.fail(WebsocketCloseProtocolError, "invalid payload length")
+			return
+		}
+		length = int64(value)
+	}
+	if opcode >= WebsocketCloseMessage {
+		if !final || length > websocketMaxControlPayload {
+			err = wc.fail(WebsocketCloseProtocolError, "invalid control frame")
+			return
+		}
+	} else if length > wc.readLimit-read { // 不使用 read+length，避免溢出
+		err = wc.fail(WebsocketCloseMessageTooBig, ErrWebsocketMessageTooBig.Error())
+		return
+	}
+	mask := make([]byte, 4)
+	if _, err = io.ReadFull(wc.reader, mask); nil != err {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(wc.reader, payload); nil != err {
+		return
+	}
+	for index := range payload {
+		payload[index] ^= mask[index%4]
+	}
+	return
+}
+
+// handlePing 处理ping控制帧
+func (wc *WebsocketConn) handlePing(payload []byte) error {
+	if nil != wc.pingHandler {
+		return wc.pingHandler(payload)
+	}
+	err := wc.Pong(payload)
+	if err == ErrWebsocketClosed {
+		return nil
+	}
+	return err
+}
+
+// handleClose 处理关闭控制帧，回复关闭帧并关闭连接
+func (wc *WebsocketConn) handleClose(payload []byte) error {
+	closeErr := &WebsocketCloseError{Code: WebsocketCloseNoStatusReceived}
+	if len(payload) == 1 {
+		return wc.fail(WebsocketCloseProtocolError, "invalid close payload")
+	} else if len(payload) >= 2 {
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+		if !utf8.ValidString(closeErr.Text) {
+			return wc.fail(WebsocketCloseInvalidPayload, "invalid utf8 close reason")
+		}
+	}
+	code := closeErr.Code
+	if code == WebsocketCloseNoStatusReceived {
+		code = WebsocketCloseNormalClosure
+	}
+	_ = wc.WriteClose(code, "")
+	_ = wc.conn.Close()
+	return closeErr
+}
+
+// fail 因协议错误以指定状态码关闭连接
+func (wc *WebsocketConn) fail(code int, text string) error {
+	_ = wc.WriteClose(code, text)
+	_ = wc.conn.Close()
+	return &WebsocketCloseError{Code: code, Text: text}
+}
+
+// WriteMessage 发送一条数据消息，设置了 WriteFragmentSize 时将分片发送
+//
+// messageType WebsocketTextMessage 或 WebsocketBinaryMessage
+func (wc *WebsocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != WebsocketTextMessage && messageType != WebsocketBinaryMessage {
+		return ErrWebsocketMessageType
+	}
+	defer wc.writeLock.Unlock()
+	wc.writeLock.Lock()
+	if wc.closeSent {
+		return ErrWebsocketClosed
+	}
+	if wc.fragmentSize <= 0 || len(data) <= wc.fragmentSize {
+		return wc.writeFrame(true, messageType, data)
+	}
+	opcode := messageType
+	for len(data) > 0 {
+		size := wc.fragmentSize
+		if size > len(data) {
+			size = len(data)
+		}
+		if err := wc.writeFrame(size == len(data), opcode, data[:size]); nil != err {
+			return err
+		}
+		data = data[size:]
+		opcode = websocketContinuationFrame
+	}
+	return nil
+}
+
+// WriteText 发送一条文本消息
+func (wc *WebsocketConn) WriteText(text string) error {
+	return wc.WriteMessage(WebsocketTextMessage, []byte(text))
+}
+
+// Ping 发送ping控制帧
+func (wc *WebsocketConn) Ping(data []byte) error {
+	return wc.writeControl(WebsocketPingMessage, data)
+}
+
+// Pong 发送pong控制帧
+func (wc *WebsocketConn) Pong(data []byte) error {
+	return wc.writeControl(WebsocketPongMessage, data)
+}
+
+// WriteClose 发送关闭控制帧，此后不可再发送数据消息
+func (wc *WebsocketConn) WriteClose(code int, text string) error {
+	payload := make([]byte, 2, 2+len(text))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, text...)
+	if len(payload) > websocketMaxControlPayload {
+		payload = payload[:websocketMaxControlPayload]
+	}
+	defer wc.writeLock.Unlock()
+	wc.writeLock.Lock()
+	if wc.closeSent {
+		return ErrWebsocketClosed
+	}
+	wc.closeSent = true
+	return wc.writeFrame(true, WebsocketCloseMessage, payload)
+}
+
+// Close 发送正常关闭帧后关闭底层连接
+func (wc *WebsocketConn) Close() error {
+	_ = wc.WriteClose(WebsocketCloseNormalClosure, "")
+	return wc.conn.Close()
+}
+
+func (wc *WebsocketConn) writeControl(opcode int, data []byte) error {
+	if len(data) > websocketMaxControlPayload {
+		return ErrWebsocketMessageTooBig
+	}
+	defer wc.writeLock.Unlock()
+	wc.writeLock.Lock()
+	if wc.closeSent {
+		return ErrWebsocketClosed
+	}
+	return wc.writeFrame(true, opcode, data)
+}
+
+// writeFrame 写入一个帧，服务端发送的帧无需掩码
+func (wc *WebsocketConn) writeFrame(final bool, opcode int, payload []byte) error {
+	header := make([]byte, 2, 10)
+	header[0] = byte(opcode)
+	if final {
+		header[0] |= websocketFinalBit
+	}
+	length := len(payload)
+	switch {
+	case length <= websocketMaxControlPayload:
+		header[1] = byte(length)
+	case length <= 0xffff:
+		header[1] = 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header[1] = 127
+		header = append(header, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+	buffers := net.Buffers{header, payload}
+	_, err := buffers.WriteTo(wc.conn)
+	return err
+}
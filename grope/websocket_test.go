@@ -0,0 +1,207 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebsocket(t *testing.T) {
+	done := make(chan error, 1)
+	hs := NewHTTPServe()
+	_ = hs.Group("/ws").Get("/echo", func(ctx *Context) {
+		conn, err := ctx.UpgradeWebsocket(&WebsocketConfig{ReadLimit: 16, Subprotocols: []string{"chat"}})
+		if nil != err {
+			done <- err
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if nil != err {
+				done <- err
+				return
+			}
+			if err = conn.WriteMessage(messageType, data); nil != err {
+				done <- err
+				return
+			}
+		}
+	})
+	server := httptest.NewServer(hs)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	_, _ = io.WriteString(conn, "GET /ws/echo HTTP/1.1\r\nHost: "+strings.TrimPrefix(server.URL, "http://")+
+		"\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Protocol: other, chat\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" ||
+		resp.Header.Get("Sec-WebSocket-Protocol") != "chat" {
+		t.Fatalf("handshake = %d %v", resp.StatusCode, resp.Header)
+	}
+
+	// 分片文本消息，中间穿插ping控制帧
+	writeClientFrame(conn, false, WebsocketTextMessage, []byte("hel"))
+	writeClientFrame(conn, true, WebsocketPingMessage, []byte("p"))
+	writeClientFrame(conn, true, websocketContinuationFrame, []byte("lo"))
+	if opcode, payload := readServerFrame(t, reader); opcode != WebsocketPongMessage || string(payload) != "p" {
+		t.Fatalf("pong = %d %q", opcode, payload)
+	}
+	if opcode, payload := readServerFrame(t, reader); opcode != WebsocketTextMessage || string(payload) != "hello" {
+		t.Fatalf("echo = %d %q", opcode, payload)
+	}
+
+	// 超出消息大小限制
+	writeClientFrame(conn, true, WebsocketBinaryMessage, make([]byte, 17))
+	opcode, payload := readServerFrame(t, reader)
+	if opcode != WebsocketCloseMessage || binary.BigEndian.Uint16(payload) != WebsocketCloseMessageTooBig {
+		t.Fatalf("close = %d %v", opcode, payload)
+	}
+	if err = <-done; nil == err {
+		t.Fatal("expected close error")
+	} else if closeErr, ok := err.(*WebsocketCloseError); !ok || closeErr.Code != WebsocketCloseMessageTooBig {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestWebsocketOversizedContinuation(t *testing.T) {
+	done := make(chan error, 1)
+	hs := NewHTTPServe()
+	_ = hs.Group("/ws").Get("/read", func(ctx *Context) {
+		conn, err := ctx.UpgradeWebsocket(&WebsocketConfig{ReadLimit: 16})
+		if nil != err {
+			done <- err
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, _, err = conn.ReadMessage()
+		done <- err
+	})
+	server := httptest.NewServer(hs)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, _ = io.WriteString(conn, "GET /ws/read HTTP/1.1\r\nHost: "+strings.TrimPrefix(server.URL, "http://")+
+		"\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	if resp, err := http.ReadResponse(reader, nil); nil != err || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake = %v %v", resp, err)
+	}
+
+	// 首个分片1字节，后续分片声明长度为 2^63-1，累计长度溢出int64
+	writeClientFrame(conn, false, WebsocketBinaryMessage, []byte("a"))
+	frame := []byte{websocketFinalBit | websocketContinuationFrame, websocketMaskBit | 127}
+	frame = append(frame, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	_, _ = conn.Write(append(frame, 1, 2, 3, 4))
+	opcode, payload := readServerFrame(t, reader)
+	if opcode != WebsocketCloseMessage || binary.BigEndian.Uint16(payload) != WebsocketCloseMessageTooBig {
+		t.Fatalf("close = %d %v", opcode, payload)
+	}
+	if closeErr, ok := (<-done).(*WebsocketCloseError); !ok || closeErr.Code != WebsocketCloseMessageTooBig {
+		t.Fatalf("err = %v", closeErr)
+	}
+
+	// 最高位为1的长度为协议错误
+	conn2, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn2.Close() }()
+	_ = conn2.SetDeadline(time.Now().Add(5 * time.Second))
+	_, _ = io.WriteString(conn2, "GET /ws/read HTTP/1.1\r\nHost: "+strings.TrimPrefix(server.URL, "http://")+
+		"\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	reader = bufio.NewReader(conn2)
+	if resp, err := http.ReadResponse(reader, nil); nil != err || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake = %v %v", resp, err)
+	}
+	frame = []byte{websocketFinalBit | WebsocketBinaryMessage, websocketMaskBit | 127}
+	frame = append(frame, 0x80, 0, 0, 0, 0, 0, 0, 1)
+	_, _ = conn2.Write(append(frame, 1, 2, 3, 4))
+	if opcode, payload = readServerFrame(t, reader); opcode != WebsocketCloseMessage ||
+		binary.BigEndian.Uint16(payload) != WebsocketCloseProtocolError {
+		t.Fatalf("close = %d %v", opcode, payload)
+	}
+	<-done
+}
+
+func TestWebsocketHandshakeRejected(t *testing.T) {
+	hs := NewHTTPServe()
+	_ = hs.Group("/ws").Get("/echo", func(ctx *Context) {
+		if _, err := ctx.UpgradeWebsocket(nil); nil == err {
+			t.Error("expected handshake error")
+		}
+	})
+	req := httptest.NewRequest(http.MethodGet, "/ws/echo", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Origin", "http://evil.com")
+	rec := httptest.NewRecorder()
+	hs.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d", rec.Code)
+	}
+}
+
+func writeClientFrame(w io.Writer, final bool, opcode int, payload []byte) {
+	header := []byte{byte(opcode), websocketMaskBit | byte(len(payload))}
+	if final {
+		header[0] |= websocketFinalBit
+	}
+	mask := []byte{1, 2, 3, 4}
+	masked := make([]byte, len(payload))
+	for index := range payload {
+		masked[index] = payload[index] ^ mask[index%4]
+	}
+	_, _ = w.Write(append(append(header, mask...), masked...))
+}
+
+func readServerFrame(t *testing.T, r io.Reader) (int, []byte) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); nil != err {
+		t.Fatal(err)
+	}
+	payload := make([]byte, header[1]&0x7f)
+	if _, err := io.ReadFull(r, payload); nil != err {
+		t.Fatal(err)
+	}
+	return int(header[0] & 0x0f), payload
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grope
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+var (
+	// ErrResponseHijacker response writer does not implement http.Hijacker
+	ErrResponseHijacker = errors.New("response writer does not implement http.Hijacker")
+)
+
+// responseWriter 记录响应状态码及响应内容大小的 http.ResponseWriter
+type responseWriter struct {
+	http.ResponseWriter
+	status int   // 已写入的状态码，0表示尚未写入
+	size   int64 // 已写入的响应内容字节数
+}
+
+// WriteHeader 写入状态码，仅记录首个最终状态码
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 && statusCode >= http.StatusOK {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write 写入响应内容，未写入状态码时视为 200 OK
+func (w *responseWriter) Write(bytes []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(bytes)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush 将缓冲数据刷新至客户端
+func (w *responseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		flusher.Flush()
+	}
+}
+
+// Hijack 接管连接，接管后状态码视为 101 Switching Protocols
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, ErrResponseHijacker
+	}
+	conn, brw, err := hijacker.Hijack()
+	if nil == err && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+// Push 发起 HTTP/2 服务端推送
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := w.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap 返回原始 http.ResponseWriter，供 http.ResponseController 使用
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -15,25 +15,16 @@
 package gnomon
 
 import (
-	"bytes"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/json"
-	"encoding/xml"
 	"github.com/golang/protobuf/proto"
-	"github.com/vmihailenco/msgpack"
-	"gopkg.in/yaml.v3"
-	"io"
 	"io/ioutil"
-	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
-	"strings"
-	"sync"
 )
 
 // HTTPGet get 请求
+//
+// HTTP* 系列方法基于共享的 HTTPClient 实现，需要设置请求头、params、上下文或超时时间时直接使用 HTTPClient
 func HTTPGet(url string) (resp *http.Response, err error) {
 	return HTTPGetTLS(url, &HTTPTLSConfig{})
 }
@@ -334,12 +325,12 @@ func HTTPDoTLS(req *http.Request, tlsConfig *HTTPTLSConfig) (resp *http.Response
 
 // HTTPGetTLSBytes get tls 请求
 func HTTPGetTLSBytes(url string, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestTLSBytes(http.MethodGet, url, "", nil, tlsConfig)
+	return httpTLSClient(tlsConfig).Get(url).Send()
 }
 
 // HTTPGetHostTLSBytes get tls 请求
 func HTTPGetHostTLSBytes(url, host string, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestTLSBytes(http.MethodGet, url, host, nil, tlsConfig)
+	return httpTLSClient(tlsConfig).Get(url).Host(host).Send()
 }
 
 //func HttpGetTLSBytesProxy(expectURL, proxyURL string, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
@@ -350,142 +341,146 @@ func HTTPGetHostTLSBytes(url, host string, tlsConfig *HTTPTLSBytesConfig) (resp
 //
 // content-type=application/json
 func HTTPPostJSONTLSBytes(url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestJSON(http.MethodPost, url, model, tlsConfig)
+	return httpTLSClient(tlsConfig).Post(url).JSON(model).Send()
 }
 
 // HTTPPutJSONTLSBytes put tls 请求
 //
 // content-type=application/json
 func HTTPPutJSONTLSBytes(url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestJSON(http.MethodPut, url, model, tlsConfig)
+	return httpTLSClient(tlsConfig).Put(url).JSON(model).Send()
 }
 
 // HTTPPatchJSONTLSBytes patch tls 请求
 //
 // content-type=application/json
 func HTTPPatchJSONTLSBytes(url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestJSON(http.MethodPatch, url, model, tlsConfig)
+	return httpTLSClient(tlsConfig).Patch(url).JSON(model).Send()
 }
 
 // HTTPDeleteJSONTLSBytes delete tls 请求
 func HTTPDeleteJSONTLSBytes(url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestJSON(http.MethodDelete, url, model, tlsConfig)
+	return httpTLSClient(tlsConfig).Delete(url).JSON(model).Send()
 }
 
 // HTTPPostXMLTLSBytes post tls 请求
 //
 // content-type=application/xml
 func HTTPPostXMLTLSBytes(url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestXML(http.MethodPost, url, model, tlsConfig)
+	return httpTLSClient(tlsConfig).Post(url).XML(model).Send()
 }
 
 // HTTPPutXMLTLSBytes put tls 请求
 //
 // content-type=application/xml
 func HTTPPutXMLTLSBytes(url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestXML(http.MethodPut, url, model, tlsConfig)
+	return httpTLSClient(tlsConfig).Put(url).XML(model).Send()
 }
 
 // HTTPPatchXMLTLSBytes patch tls 请求
 //
 // content-type=application/xml
 func HTTPPatchXMLTLSBytes(url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestXML(http.MethodPatch, url, model, tlsConfig)
+	return httpTLSClient(tlsConfig).Patch(url).XML(model).Send()
 }
 
 // HTTPDeleteXMLTLSBytes delete tls 请求
 func HTTPDeleteXMLTLSBytes(url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestXML(http.MethodDelete, url, model, tlsConfig)
+	return httpTLSClient(tlsConfig).Delete(url).XML(model).Send()
 }
 
 // HTTPPostYamlTLSBytes post tls 请求
 //
 // content-type=application/x-yaml
 func HTTPPostYamlTLSBytes(url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestYaml(http.MethodPost, url, model, tlsConfig)
+	return httpTLSClient(tlsConfig).Post(url).Yaml(model).Send()
 }
 
 // HTTPPutYamlTLSBytes put tls 请求
 //
 // content-type=application/x-yaml
 func HTTPPutYamlTLSBytes(url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestYaml(http.MethodPut, url, model, tlsConfig)
+	return httpTLSClient(tlsConfig).Put(url).Yaml(model).Send()
 }
 
 // HTTPPatchYamlTLSBytes patch tls 请求
 //
 // content-type=application/x-yaml
 func HTTPPatchYamlTLSBytes(url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestYaml(http.MethodPatch, url, model, tlsConfig)
+	return httpTLSClient(tlsConfig).Patch(url).Yaml(model).Send()
 }
 
 // HTTPDeleteYamlTLSBytes delete tls 请求
 func HTTPDeleteYamlTLSBytes(url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestYaml(http.MethodDelete, url, model, tlsConfig)
+	return httpTLSClient(tlsConfig).Delete(url).Yaml(model).Send()
 }
 
 // HTTPPostMsgPackTLSBytes post tls 请求
 //
 // content-type=application/x-msgpack
 func HTTPPostMsgPackTLSBytes(url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestMsgPack(http.MethodPost, url, model, tlsConfig)
+	return httpTLSClient(tlsConfig).Post(url).MsgPack(model).Send()
 }
 
 // HTTPPutMsgPackTLSBytes put tls 请求
 //
 // content-type=application/x-msgpack
 func HTTPPutMsgPackTLSBytes(url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestMsgPack(http.MethodPut, url, model, tlsConfig)
+	return httpTLSClient(tlsConfig).Put(url).MsgPack(model).Send()
 }
 
 // HTTPPatchMsgPackTLSBytes patch tls 请求
 //
 // content-type=application/x-msgpack
 func HTTPPatchMsgPackTLSBytes(url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestMsgPack(http.MethodPatch, url, model, tlsConfig)
+	return httpTLSClient(tlsConfig).Patch(url).MsgPack(model).Send()
 }
 
 // HTTPDeleteMsgPackTLSBytes delete tls 请求
 func HTTPDeleteMsgPackTLSBytes(url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestMsgPack(http.MethodDelete, url, model, tlsConfig)
+	return httpTLSClient(tlsConfig).Delete(url).MsgPack(model).Send()
 }
 
 // HTTPPostProtoBufTLSBytes post tls 请求
 //
 // content-type=application/x-protobuf
 func HTTPPostProtoBufTLSBytes(url string, pm proto.Message, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestProtoBuf(http.MethodPost, url, pm, tlsConfig)
+	return httpTLSClient(tlsConfig).Post(url).ProtoBuf(pm).Send()
 }
 
 // HTTPPutProtoBufTLSBytes put tls 请求
 //
 // content-type=application/x-protobuf
 func HTTPPutProtoBufTLSBytes(url string, pm proto.Message, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestProtoBuf(http.MethodPut, url, pm, tlsConfig)
+	return httpTLSClient(tlsConfig).Put(url).ProtoBuf(pm).Send()
 }
 
 // HTTPPatchProtoBufTLSBytes patch tls 请求
 //
 // content-type=application/x-protobuf
 func HTTPPatchProtoBufTLSBytes(url string, pm proto.Message, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestProtoBuf(http.MethodPatch, url, pm, tlsConfig)
+	return httpTLSClient(tlsConfig).Patch(url).ProtoBuf(pm).Send()
 }
 
 // HTTPDeleteProtoBufTLSBytes delete tls 请求
 //
 // content-type=application/x-protobuf
 func HTTPDeleteProtoBufTLSBytes(url string, pm proto.Message, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestProtoBuf(http.MethodDelete, url, pm, tlsConfig)
+	return httpTLSClient(tlsConfig).Delete(url).ProtoBuf(pm).Send()
 }
 
 // HTTPDeleteTLSBytes delete tls 请求
 func HTTPDeleteTLSBytes(url string, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestTLSBytes(http.MethodDelete, url, "", nil, tlsConfig)
+	return httpTLSClient(tlsConfig).Delete(url).Send()
 }
 
 // HTTPDoTLSBytes 处理 tls 请求
 func HTTPDoTLSBytes(req *http.Request, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestTLSBytesDo(req, tlsConfig)
+	client, err := httpTLSClient(tlsConfig).Client()
+	if nil != err {
+		return nil, err
+	}
+	return client.Do(req)
 }
 
 // HTTPPostForm post 请求
@@ -602,7 +597,7 @@ func HTTPPatchFormMultipartTLS(url string, paramMap map[string]string, fileMap m
 //
 // fileMap form附件key及附件路径
 func HTTPPostFormTLSBytes(url string, paramMap map[string]string, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestForm(http.MethodPost, url, paramMap, tlsConfig)
+	return httpTLSClient(tlsConfig).Post(url).Form(paramMap).Send()
 }
 
 // HTTPPutFormTLSBytes put tls 请求
@@ -611,7 +606,7 @@ func HTTPPostFormTLSBytes(url string, paramMap map[string]string, tlsConfig *HTT
 //
 // fileMap form附件key及附件路径
 func HTTPPutFormTLSBytes(url string, paramMap map[string]string, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestForm(http.MethodPut, url, paramMap, tlsConfig)
+	return httpTLSClient(tlsConfig).Put(url).Form(paramMap).Send()
 }
 
 // HTTPPatchFormTLSBytes patch tls 请求
@@ -620,7 +615,7 @@ func HTTPPutFormTLSBytes(url string, paramMap map[string]string, tlsConfig *HTTP
 //
 // fileMap form附件key及附件路径
 func HTTPPatchFormTLSBytes(url string, paramMap map[string]string, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestForm(http.MethodPatch, url, paramMap, tlsConfig)
+	return httpTLSClient(tlsConfig).Patch(url).Form(paramMap).Send()
 }
 
 // HTTPPostFormMultipartTLSBytes post tls 请求
@@ -629,7 +624,7 @@ func HTTPPatchFormTLSBytes(url string, paramMap map[string]string, tlsConfig *HT
 //
 // fileMap form附件key及附件路径
 func HTTPPostFormMultipartTLSBytes(url string, paramMap map[string]string, fileMap map[string]string, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestFormMultipart(http.MethodPost, url, paramMap, fileMap, tlsConfig)
+	return httpTLSClient(tlsConfig).Post(url).FormMultipart(paramMap, fileMap).Send()
 }
 
 // HTTPPutFormMultipartTLSBytes put tls 请求
@@ -638,7 +633,7 @@ func HTTPPostFormMultipartTLSBytes(url string, paramMap map[string]string, fileM
 //
 // fileMap form附件key及附件路径
 func HTTPPutFormMultipartTLSBytes(url string, paramMap map[string]string, fileMap map[string]string, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestFormMultipart(http.MethodPut, url, paramMap, fileMap, tlsConfig)
+	return httpTLSClient(tlsConfig).Put(url).FormMultipart(paramMap, fileMap).Send()
 }
 
 // HTTPPatchFormMultipartTLSBytes patch tls 请求
@@ -647,220 +642,7 @@ func HTTPPutFormMultipartTLSBytes(url string, paramMap map[string]string, fileMa
 //
 // fileMap form附件key及附件路径
 func HTTPPatchFormMultipartTLSBytes(url string, paramMap map[string]string, fileMap map[string]string, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	return httpRequestFormMultipart(http.MethodPatch, url, paramMap, fileMap, tlsConfig)
-}
-
-// httpRequestJSON json 请求
-//
-// model 结构体
-func httpRequestJSON(method, url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	var (
-		data []byte
-		req  *http.Request
-	)
-	if data, err = json.Marshal(model); err != nil {
-		return nil, err
-	}
-	if req, err = http.NewRequest(method, url, bytes.NewReader(data)); nil != err {
-		return
-	}
-	req.Header.Set("content-type", "application/json")
-	return httpRequestTLSBytesDo(req, tlsConfig)
-}
-
-// httpRequestXML xml 请求
-//
-// model 结构体
-func httpRequestXML(method, url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	var (
-		data []byte
-		req  *http.Request
-	)
-	if data, err = xml.Marshal(model); err != nil {
-		return nil, err
-	}
-	if req, err = http.NewRequest(method, url, bytes.NewReader(data)); nil != err {
-		return
-	}
-	req.Header.Set("content-type", "application/xml")
-	return httpRequestTLSBytesDo(req, tlsConfig)
-}
-
-// httpRequestYaml yaml 请求
-//
-// model 结构体
-func httpRequestYaml(method, url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	var (
-		data []byte
-		req  *http.Request
-	)
-	if data, err = yaml.Marshal(model); err != nil {
-		return nil, err
-	}
-	if req, err = http.NewRequest(method, url, bytes.NewReader(data)); nil != err {
-		return
-	}
-	req.Header.Set("content-type", "application/x-yaml")
-	return httpRequestTLSBytesDo(req, tlsConfig)
-}
-
-// httpRequestMsgPack msgpack 请求
-//
-// model 结构体
-func httpRequestMsgPack(method, url string, model interface{}, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	var (
-		data []byte
-		req  *http.Request
-	)
-	if data, err = msgpack.Marshal(model); err != nil {
-		return nil, err
-	}
-	if req, err = http.NewRequest(method, url, bytes.NewReader(data)); nil != err {
-		return
-	}
-	req.Header.Set("content-type", "application/x-msgpack")
-	return httpRequestTLSBytesDo(req, tlsConfig)
-}
-
-// httpRequestProtoBuf protobuf 请求
-//
-// model 结构体
-func httpRequestProtoBuf(method, url string, pm proto.Message, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	var (
-		data []byte
-		req  *http.Request
-	)
-	if data, err = proto.Marshal(pm); err != nil {
-		return nil, err
-	}
-	if req, err = http.NewRequest(method, url, bytes.NewReader(data)); nil != err {
-		return
-	}
-	req.Header.Set("content-type", "application/x-protobuf")
-	return httpRequestTLSBytesDo(req, tlsConfig)
-}
-
-// httpRequestForm
-//
-// paramMap form普通参数
-//
-// fileMap form附件key及附件路径
-func httpRequestForm(method, url string, paramMap map[string]string, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	var (
-		req        *http.Request
-		bodyBuffer = &bytes.Buffer{}
-		bodyWriter = multipart.NewWriter(bodyBuffer)
-	)
-	for key, value := range paramMap {
-		if err = bodyWriter.WriteField(key, value); nil != err {
-			return nil, err
-		}
-	}
-	if err = bodyWriter.Close(); nil != err {
-		return nil, err
-	}
-	if req, err = http.NewRequest(method, url, bodyBuffer); nil != err {
-		return
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	return httpRequestTLSBytesDo(req, tlsConfig)
-}
-
-// httpRequestFormMultipart
-//
-// paramMap form普通参数
-//
-// fileMap form附件key及附件路径
-func httpRequestFormMultipart(method, url string, paramMap map[string]string, fileMap map[string]string, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	var (
-		req        *http.Request
-		bodyBuffer = &bytes.Buffer{}
-		bodyWriter = multipart.NewWriter(bodyBuffer)
-	)
-	for key, value := range paramMap {
-		if err = bodyWriter.WriteField(key, value); nil != err {
-			return nil, err
-		}
-	}
-	for key, value := range fileMap {
-		var (
-			fileSplitArr []string
-			fileWriter   io.Writer
-			file         *os.File
-		)
-		fileSplitArr = strings.Split(value, string(filepath.Separator))
-		if fileWriter, err = bodyWriter.CreateFormFile(key, fileSplitArr[len(fileSplitArr)-1]); nil != err {
-			return nil, err
-		}
-		if file, err = os.Open(value); nil != err {
-			return nil, err
-		}
-		if _, err = io.Copy(fileWriter, file); nil != err {
-			return nil, err
-		}
-		func() { _ = file.Close() }()
-	}
-	if err = bodyWriter.Close(); nil != err {
-		return nil, err
-	}
-	if req, err = http.NewRequest(method, url, bodyBuffer); nil != err {
-		return
-	}
-	req.Header.Set("Content-Type", "multipart/form-data")
-	return httpRequestTLSBytesDo(req, tlsConfig)
-}
-
-func httpRequestTLSBytes(method, url, host string, body io.Reader, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	var req *http.Request
-	if req, err = http.NewRequest(method, url, body); nil != err {
-		return
-	}
-	if StringIsNotEmpty(host) {
-		req.Host = host
-	}
-	return httpRequestTLSBytesDo(req, tlsConfig)
-}
-
-func httpRequestTLSBytesDo(req *http.Request, tlsConfig *HTTPTLSBytesConfig) (resp *http.Response, err error) {
-	var (
-		tlsClient    *http.Client
-		tlsClientKey string
-	)
-	if nil == tlsConfig {
-		tlsClientKey = ""
-	} else {
-		bs := append(tlsConfig.RootCrtBytes, append(tlsConfig.KeyBytes, tlsConfig.CertBytes...)...)
-		tlsClientKey = HashMD516Bytes(bs)
-	}
-	if tlsClient, err = getTLSClient(tlsClientKey, tlsConfig); nil != err {
-		return
-	}
-	return tlsClient.Do(req)
-}
-
-var (
-	clients    = map[string]*http.Client{}
-	clientLock sync.Mutex
-)
-
-func getTLSClient(tlsClientKey string, tlsConfig *HTTPTLSBytesConfig) (*http.Client, error) {
-	if tlsClient, exist := clients[tlsClientKey]; exist {
-		return tlsClient, nil
-	}
-	defer clientLock.Unlock()
-	clientLock.Lock()
-	if tlsClient, exist := clients[tlsClientKey]; exist {
-		return tlsClient, nil
-	}
-	var (
-		tlsClient = &http.Client{}
-		err       error
-	)
-	if tlsClient.Transport, err = getTLSTransport(tlsConfig); nil != err {
-		return nil, err
-	}
-	clients[tlsClientKey] = tlsClient
-	return tlsClient, nil
+	return httpTLSClient(tlsConfig).Patch(url).FormMultipart(paramMap, fileMap).Send()
 }
 
 func getTLSTransport(tlsConfig *HTTPTLSBytesConfig) (transport *http.Transport, err error) {
@@ -0,0 +1,523 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gnomon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"github.com/golang/protobuf/proto"
+	"github.com/vmihailenco/msgpack"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPClient 可配置的http客户端，创建后可被多个协程共享，首次发起请求后修改配置不再生效
+type HTTPClient struct {
+	BaseURL               string              // 基础地址，如“https://api.example.com/v1”，请求路径为完整地址时忽略
+	Header                http.Header         // 默认请求头，请求中设置的同名请求头优先
+	Timeout               time.Duration       // 单次请求的总超时时间，包含读取应答内容，0表示不限
+	DialTimeout           time.Duration       // 建立连接的超时时间，0表示不限
+	ResponseHeaderTimeout time.Duration       // 发送请求后等待应答头的超时时间，0表示不限
+	TLSConfig             *HTTPTLSConfig      // tls 证书文件配置，TLSBytesConfig 为nil时使用
+	TLSBytesConfig        *HTTPTLSBytesConfig // tls 证书内容配置
+	Transport             http.RoundTripper   // 自定义传输层，设置后忽略tls及连接超时配置
+	Endpoints             *HTTPEndpoints      // 多地址端点集合，设置后替代 BaseURL
+	Retry                 HTTPRetrier         // 重试策略，如 DefaultHTTPRetryPolicy，nil表示不重试
+	MaxResponseBytes      int64               // Do 允许读取的最大应答字节数，0表示 HTTPDefaultMaxResponseBytes，小于0表示不限
+
+	client *http.Client
+	once   sync.Once
+	err    error
+}
+
+// NewHTTPClient 新建http客户端
+//
+// baseURL 基础地址，如“https://api.example.com/v1”，可为空，此时请求路径须为完整地址
+func NewHTTPClient(baseURL string) *HTTPClient {
+	return &HTTPClient{BaseURL: baseURL, Header: http.Header{}}
+}
+
+// init 根据配置创建 http.Client
+func (hc *HTTPClient) init() error {
+	hc.once.Do(func() {
+		transport := hc.Transport
+		if nil == transport {
+			tlsConfig := hc.TLSBytesConfig
+			if nil == tlsConfig && nil != hc.TLSConfig {
+				tlsConfig = hc.TLSConfig.trans()
+			}
+			if nil == tlsConfig {
+				tlsConfig = &HTTPTLSBytesConfig{}
+			}
+			tlsTransport, err := getTLSTransport(tlsConfig)
+			if nil != err {
+				hc.err = err
+				return
+			}
+			if hc.DialTimeout > 0 {
+				tlsTransport.DialContext = (&net.Dialer{Timeout: hc.DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+			}
+			tlsTransport.ResponseHeaderTimeout = hc.ResponseHeaderTimeout
+			transport = tlsTransport
+		}
+		hc.client = &http.Client{Transport: transport, Timeout: hc.Timeout}
+	})
+	return hc.err
+}
+
+// Client 获取客户端使用的 http.Client，可用于发送自行构建的请求
+func (hc *HTTPClient) Client() (*http.Client, error) {
+	if err := hc.init(); nil != err {
+		return nil, err
+	}
+	return hc.client, nil
+}
+
+// httpJoinURL 拼接基础地址与请求路径，请求路径为完整地址时忽略基础地址
+func httpJoinURL(baseURL, path string) string {
+	if StringIsEmpty(baseURL) || strings.Contains(path, "://") {
+		return path
+	}
+	if StringIsEmpty(path) {
+		return baseURL
+	}
+	return StringBuild(strings.TrimRight(baseURL, "/"), "/", strings.TrimLeft(path, "/"))
+}
+
+// Get 新建get请求
+func (hc *HTTPClient) Get(path string) *HTTPRequest {
+	return hc.NewRequest(http.MethodGet, path)
+}
+
+// Head 新建head请求
+func (hc *HTTPClient) Head(path string) *HTTPRequest {
+	return hc.NewRequest(http.MethodHead, path)
+}
+
+// Post 新建post请求
+func (hc *HTTPClient) Post(path string) *HTTPRequest {
+	return hc.NewRequest(http.MethodPost, path)
+}
+
+// Put 新建put请求
+func (hc *HTTPClient) Put(path string) *HTTPRequest {
+	return hc.NewRequest(http.MethodPut, path)
+}
+
+// Patch 新建patch请求
+func (hc *HTTPClient) Patch(path string) *HTTPRequest {
+	return hc.NewRequest(http.MethodPatch, path)
+}
+
+// Delete 新建delete请求
+func (hc *HTTPClient) Delete(path string) *HTTPRequest {
+	return hc.NewRequest(http.MethodDelete, path)
+}
+
+// NewRequest 新建请求
+//
+// path 请求路径，与基础地址拼接，如“/users/1?fields=name”，也可为完整地址
+func (hc *HTTPClient) NewRequest(method, path string) *HTTPRequest {
+	return &HTTPRequest{client: hc, method: method, path: path, query: url.Values{}, header: http.Header{}}
+}
+
+// HTTPRequest http请求构建器，通过 Do 或 Send 发起请求
+type HTTPRequest struct {
+	client           *HTTPClient
+	method           string
+	path             string
+	host             string
+	query            url.Values
+	header           http.Header
+	ctx              context.Context
+	timeout          time.Duration
+	body             func() (io.Reader, error) // 获取请求内容，每次调用返回新的读取器
+	replayable       bool                      // 请求内容是否可重放
+	retry            HTTPRetrier               // 重试策略，nil表示使用客户端配置
+	uploadProgress   HTTPProgress              // 请求内容发送进度回调
+	downloadProgress HTTPProgress              // 应答内容读取进度回调
+	errorModel       interface{}               // 非2xx应答的解析结构体
+	maxBytes         int64                     // 允许读取的最大应答字节数，0表示使用客户端配置
+	err              error                     // 构建请求内容时的错误，在 Send 时返回
+}
+
+// Query 追加请求params
+func (hr *HTTPRequest) Query(key string, values ...string) *HTTPRequest {
+	for _, value := range values {
+		hr.query.Add(key, value)
+	}
+	return hr
+}
+
+// Header 设置请求头
+func (hr *HTTPRequest) Header(key, value string) *HTTPRequest {
+	hr.header.Set(key, value)
+	return hr
+}
+
+// Host 设置请求的主机名，用于通过IP访问指定虚拟主机
+func (hr *HTTPRequest) Host(host string) *HTTPRequest {
+	hr.host = host
+	return hr
+}
+
+// Context 设置请求的上下文，上下文取消时中断请求及应答内容的读取
+func (hr *HTTPRequest) Context(ctx context.Context) *HTTPRequest {
+	hr.ctx = ctx
+	return hr
+}
+
+// Timeout 设置本次请求的超时时间，包含读取应答内容，与客户端超时时间同时生效
+func (hr *HTTPRequest) Timeout(timeout time.Duration) *HTTPRequest {
+	hr.timeout = timeout
+	return hr
+}
+
+// Body 设置请求内容及内容类型
+//
+// body 为 *bytes.Buffer、*bytes.Reader 或 *strings.Reader 时读取至内存以便重试时重放，其余类型仅能发送一次，不会被重试
+func (hr *HTTPRequest) Body(contentType string, body io.Reader) *HTTPRequest {
+	switch body.(type) {
+	case *bytes.Buffer, *bytes.Reader, *strings.Reader:
+		data, err := ioutil.ReadAll(body)
+		if nil != err {
+			hr.err = err
+		}
+		return hr.Bytes(contentType, data)
+	}
+	hr.header.Set("Content-Type", contentType)
+	hr.body = func() (io.Reader, error) {
+		return body, nil
+	}
+	hr.replayable = false
+	return hr
+}
+
+// BodyFunc 设置请求内容及内容类型，每次发送（包括重试）时调用body获取新的请求内容，适用于无法读取至内存的流式内容
+func (hr *HTTPRequest) BodyFunc(contentType string, body func() (io.Reader, error)) *HTTPRequest {
+	hr.header.Set("Content-Type", contentType)
+	hr.body = body
+	hr.replayable = true
+	return hr
+}
+
+// Bytes 设置请求内容及内容类型
+func (hr *HTTPRequest) Bytes(contentType string, data []byte) *HTTPRequest {
+	return hr.BodyFunc(contentType, func() (io.Reader, error) {
+		return bytes.NewReader(data), nil
+	})
+}
+
+// JSON 设置请求内容，content-type=application/json
+func (hr *HTTPRequest) JSON(model interface{}) *HTTPRequest {
+	return hr.marshal("application/json", json.Marshal, model)
+}
+
+// XML 设置请求内容，content-type=application/xml
+func (hr *HTTPRequest) XML(model interface{}) *HTTPRequest {
+	return hr.marshal("application/xml", xml.Marshal, model)
+}
+
+// Yaml 设置请求内容，content-type=application/x-yaml
+func (hr *HTTPRequest) Yaml(model interface{}) *HTTPRequest {
+	return hr.marshal("application/x-yaml", yaml.Marshal, model)
+}
+
+// MsgPack 设置请求内容，content-type=application/x-msgpack
+func (hr *HTTPRequest) MsgPack(model interface{}) *HTTPRequest {
+	return hr.marshal("application/x-msgpack", msgpack.Marshal, model)
+}
+
+// ProtoBuf 设置请求内容，content-type=application/x-protobuf
+func (hr *HTTPRequest) ProtoBuf(pm proto.Message) *HTTPRequest {
+	data, err := proto.Marshal(pm)
+	if nil != err {
+		hr.err = err
+	}
+	return hr.Bytes("application/x-protobuf", data)
+}
+
+// marshal 序列化请求内容
+func (hr *HTTPRequest) marshal(contentType string, marshal func(interface{}) ([]byte, error), model interface{}) *HTTPRequest {
+	data, err := marshal(model)
+	if nil != err {
+		hr.err = err
+	}
+	return hr.Bytes(contentType, data)
+}
+
+// Form 设置表单请求内容，content-type=application/x-www-form-urlencoded
+//
+// paramMap form普通参数
+func (hr *HTTPRequest) Form(paramMap map[string]string) *HTTPRequest {
+	values := url.Values{}
+	for key, value := range paramMap {
+		values.Set(key, value)
+	}
+	return hr.Bytes("application/x-www-form-urlencoded", []byte(values.Encode()))
+}
+
+// FormMultipart 设置表单请求内容，content-type=multipart/form-data，附件在发送时流式写入
+//
+// paramMap form普通参数
+//
+// fileMap form附件key及附件路径
+func (hr *HTTPRequest) FormMultipart(paramMap map[string]string, fileMap map[string]string) *HTTPRequest {
+	form := NewHTTPMultipart()
+	for key, value := range paramMap {
+		form.Field(key, value)
+	}
+	for key, value := range fileMap {
+		form.File(key, value, "")
+	}
+	return hr.Multipart(form)
+}
+
+// build 构建 http.Request，endpoint不为nil时以其替代客户端的基础地址
+func (hr *HTTPRequest) build(ctx context.Context, endpoint *httpEndpoint) (*http.Request, error) {
+	if nil != hr.err {
+		return nil, hr.err
+	}
+	baseURL := hr.client.BaseURL
+	if nil != endpoint {
+		baseURL = endpoint.url
+	}
+	target, err := url.Parse(httpJoinURL(baseURL, hr.path))
+	if nil != err {
+		return nil, err
+	}
+	if len(hr.query) > 0 {
+		query := target.Query()
+		for key, values := range hr.query {
+			query[key] = append(query[key], values...)
+		}
+		target.RawQuery = query.Encode()
+	}
+	var body io.Reader
+	if nil != hr.body {
+		if body, err = hr.body(); nil != err {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, hr.method, target.String(), body)
+	if nil != err {
+		if closer, ok := body.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		return nil, err
+	}
+	if multipartBody, ok := body.(*httpMultipartBody); ok && multipartBody.size >= 0 {
+		req.ContentLength = multipartBody.size
+	}
+	for key, values := range hr.client.Header {
+		req.Header[key] = append([]string{}, values...)
+	}
+	for key, values := range hr.header {
+		req.Header[key] = append([]string{}, values...)
+	}
+	if StringIsNotEmpty(hr.host) {
+		req.Host = hr.host
+	}
+	if hr.replayable {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := hr.body()
+			if nil != err {
+				return nil, err
+			}
+			rc, ok := body.(io.ReadCloser)
+			if !ok {
+				rc = ioutil.NopCloser(body)
+			}
+			if nil != hr.uploadProgress {
+				rc = newHTTPProgressReader(rc, req.ContentLength, hr.uploadProgress)
+			}
+			return rc, nil
+		}
+	}
+	if nil != hr.uploadProgress && nil != req.Body && http.NoBody != req.Body {
+		req.Body = newHTTPProgressReader(req.Body, req.ContentLength, hr.uploadProgress)
+	}
+	return req, nil
+}
+
+// Build 构建 http.Request，可用于自行发送请求，设置端点集合时通过负载均衡选择端点
+func (hr *HTTPRequest) Build() (*http.Request, error) {
+	ctx := hr.ctx
+	if nil == ctx {
+		ctx = context.Background()
+	}
+	var endpoint *httpEndpoint
+	if nil != hr.client.Endpoints {
+		var err error
+		if endpoint, err = hr.client.Endpoints.acquire(nil); nil != err {
+			return nil, err
+		}
+	}
+	return hr.build(ctx, endpoint)
+}
+
+// Send 发起请求并返回原始应答，调用方负责关闭应答内容
+//
+// 设置重试策略时按策略重试，被放弃的应答将被丢弃并关闭，请求的超时时间包含全部重试
+func (hr *HTTPRequest) Send() (*http.Response, error) {
+	if err := hr.client.init(); nil != err {
+		return nil, err
+	}
+	ctx := hr.ctx
+	if nil == ctx {
+		ctx = context.Background()
+	}
+	var cancel context.CancelFunc
+	if hr.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, hr.timeout)
+	}
+	resp, err := hr.send(ctx)
+	if nil == err && nil != hr.downloadProgress {
+		resp.Body = newHTTPProgressReader(resp.Body, resp.ContentLength, hr.downloadProgress)
+	}
+	if nil != cancel {
+		if nil != err {
+			cancel()
+		} else {
+			resp.Body = &cancelReadCloser{ReadCloser: resp.Body, cancel: cancel}
+		}
+	}
+	return resp, err
+}
+
+// send 按重试策略发起请求
+func (hr *HTTPRequest) send(ctx context.Context) (*http.Response, error) {
+	retry := hr.retry
+	if nil == retry {
+		retry = hr.client.Retry
+	}
+	for attempt := 1; ; attempt++ {
+		req, resp, err := hr.do(ctx)
+		if nil == req {
+			return nil, err
+		}
+		if nil == retry || (nil != hr.body && !hr.replayable) || nil != ctx.Err() {
+			return resp, err
+		}
+		delay, ok := retry.Retry(attempt, req, resp, err)
+		if !ok {
+			return resp, err
+		}
+		if nil != resp {
+			_, _ = io.CopyN(ioutil.Discard, resp.Body, httpDrainBytes)
+			_ = resp.Body.Close()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// do 发送一次请求，设置端点集合时记录端点的请求结果，并在连接失败后切换至其它端点
+//
+// 构建请求失败时返回的 http.Request 为nil
+func (hr *HTTPRequest) do(ctx context.Context) (*http.Request, *http.Response, error) {
+	endpoints := hr.client.Endpoints
+	if nil == endpoints {
+		req, err := hr.build(ctx, nil)
+		if nil != err {
+			return nil, nil, err
+		}
+		resp, err := hr.client.client.Do(req)
+		return req, resp, err
+	}
+	var (
+		tried   map[*httpEndpoint]bool
+		lastReq *http.Request
+		lastErr error
+	)
+	for {
+		endpoint, err := endpoints.acquire(tried)
+		if nil != err {
+			if nil != lastReq {
+				return lastReq, nil, lastErr
+			}
+			return nil, nil, err
+		}
+		req, err := hr.build(ctx, endpoint)
+		if nil != err {
+			return nil, nil, err
+		}
+		resp, err := hr.client.client.Do(req)
+		if nil != ctx.Err() {
+			return req, resp, err
+		}
+		endpoints.report(endpoint, nil == err && resp.StatusCode < http.StatusInternalServerError)
+		if nil == err || !httpDialError(err) || (nil != hr.body && !hr.replayable) {
+			return req, resp, err
+		}
+		if nil == tried {
+			tried = map[*httpEndpoint]bool{}
+		}
+		tried[endpoint] = true
+		lastReq, lastErr = req, err
+	}
+}
+
+// cancelReadCloser 关闭应答内容时取消请求的上下文
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close 关闭应答内容并取消请求的上下文
+func (crc *cancelReadCloser) Close() error {
+	err := crc.ReadCloser.Close()
+	crc.cancel()
+	return err
+}
+
+var (
+	tlsClients    = map[string]*HTTPClient{}
+	tlsClientLock sync.Mutex
+)
+
+// httpTLSClient 获取 HTTP* 系列方法使用的客户端，相同tls配置共享同一客户端
+func httpTLSClient(tlsConfig *HTTPTLSBytesConfig) *HTTPClient {
+	var tlsClientKey string
+	if nil != tlsConfig {
+		bs := append(append(append([]byte{}, tlsConfig.RootCrtBytes...), tlsConfig.KeyBytes...), tlsConfig.CertBytes...)
+		tlsClientKey = HashMD516Bytes(bs)
+		if tlsConfig.InsecureSkipVerify {
+			tlsClientKey = StringBuild(tlsClientKey, "-insecure")
+		}
+	}
+	defer tlsClientLock.Unlock()
+	tlsClientLock.Lock()
+	if client, exist := tlsClients[tlsClientKey]; exist {
+		return client
+	}
+	client := &HTTPClient{TLSBytesConfig: tlsConfig}
+	tlsClients[tlsClientKey] = client
+	return client
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gnomon
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// httpEcho 以JSON应答请求的方法、路径、params、请求头及请求内容
+func httpEcho(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/slow" {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+		"query":  r.URL.Query(),
+		"host":   r.Host,
+		"ct":     r.Header.Get("Content-Type"),
+		"token":  r.Header.Get("X-Token"),
+		"trace":  r.Header.Get("X-Trace"),
+		"body":   string(body),
+	})
+}
+
+// httpEchoDecoder 解析 httpEcho 的应答
+func httpEchoDecoder(t *testing.T) func(resp *http.Response, err error) map[string]interface{} {
+	return func(resp *http.Response, err error) map[string]interface{} {
+		t.Helper()
+		if nil != err {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		result := map[string]interface{}{}
+		if err = json.NewDecoder(resp.Body).Decode(&result); nil != err {
+			t.Fatal(err)
+		}
+		return result
+	}
+}
+
+func TestHTTPClientRequest(t *testing.T) {
+	echo := httpEchoDecoder(t)
+	server := httptest.NewServer(http.HandlerFunc(httpEcho))
+	defer server.Close()
+	client := NewHTTPClient(server.URL + "/api/")
+	client.Header.Set("X-Token", "default")
+	client.Header.Set("X-Trace", "client")
+
+	result := echo(client.Post("/users?fields=name").Query("tag", "a", "b").Header("X-Trace", "request").
+		Host("api.example.com").JSON(&TestOne{One: "1", OneGo: 2}).Send())
+	if result["method"] != http.MethodPost || result["path"] != "/api/users" || result["host"] != "api.example.com" ||
+		result["ct"] != "application/json" || result["token"] != "default" || result["trace"] != "request" ||
+		result["body"] != `{"one":"1","ones":false,"one_go":2}` {
+		t.Errorf("result = %v", result)
+	}
+	if query := result["query"].(map[string]interface{}); len(query) != 2 || len(query["tag"].([]interface{})) != 2 {
+		t.Errorf("query = %v", query)
+	}
+
+	result = echo(client.Put(server.URL + "/abs").Form(map[string]string{"a": "1 2"}).Send())
+	if result["path"] != "/abs" || result["ct"] != "application/x-www-form-urlencoded" || result["body"] != "a=1+2" {
+		t.Errorf("form = %v", result)
+	}
+
+	dir, err := ioutil.TempDir("", "gnomon")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "upload.txt")
+	if err = ioutil.WriteFile(file, []byte("content"), 0644); nil != err {
+		t.Fatal(err)
+	}
+	result = echo(client.Patch("multi").FormMultipart(map[string]string{"k": "v"}, map[string]string{"f": file}).Send())
+	if ct := result["ct"].(string); !strings.HasPrefix(ct, "multipart/form-data; boundary=") ||
+		!strings.Contains(result["body"].(string), `filename="upload.txt"`) {
+		t.Errorf("multipart = %v", result)
+	}
+	if _, err := client.Post("multi").FormMultipart(nil, map[string]string{"f": file + ".none"}).Send(); !os.IsNotExist(err) {
+		t.Errorf("missing file err = %v", err)
+	}
+	if _, err := client.Post("json").JSON(make(chan int)).Send(); nil == err {
+		t.Error("expected marshal error")
+	}
+}
+
+func TestHTTPClientTimeout(t *testing.T) {
+	echo := httpEchoDecoder(t)
+	server := httptest.NewServer(http.HandlerFunc(httpEcho))
+	defer server.Close()
+	client := NewHTTPClient(server.URL)
+	start := time.Now()
+	if _, err := client.Get("/slow").Timeout(50 * time.Millisecond).Send(); nil == err {
+		t.Error("expected request timeout")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.Get("/slow").Context(ctx).Send(); nil == err {
+		t.Error("expected canceled context")
+	}
+	echo(client.Get("/fast").Timeout(time.Second).Send())
+
+	timeoutClient := &HTTPClient{BaseURL: server.URL, Timeout: 50 * time.Millisecond}
+	if _, err := timeoutClient.Get("/slow").Send(); nil == err {
+		t.Error("expected client timeout")
+	}
+	if time.Since(start) > 900*time.Millisecond {
+		t.Errorf("timeouts took %v", time.Since(start))
+	}
+}
+
+func TestHTTPClientWrappers(t *testing.T) {
+	echo := httpEchoDecoder(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(httpEcho))
+	defer server.Close()
+	tlsConfig := &HTTPTLSBytesConfig{InsecureSkipVerify: true}
+	result := echo(HTTPPostFormTLSBytes(server.URL+"/form", map[string]string{"x": "1"}, tlsConfig))
+	if result["ct"] != "application/x-www-form-urlencoded" || result["body"] != "x=1" {
+		t.Errorf("form = %v", result)
+	}
+	result = echo(HTTPGetHostTLSBytes(server.URL+"/host", "virtual.example.com", tlsConfig))
+	if result["host"] != "virtual.example.com" {
+		t.Errorf("host = %v", result)
+	}
+	if httpTLSClient(tlsConfig) != httpTLSClient(&HTTPTLSBytesConfig{InsecureSkipVerify: true}) {
+		t.Error("expected shared client")
+	}
+	if _, err := HTTPGetTLSBytes(server.URL, &HTTPTLSBytesConfig{}); nil == err {
+		t.Error("expected certificate error")
+	}
+}
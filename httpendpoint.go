@@ -0,0 +1,174 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gnomon
+
+import (
+	"errors"
+	"github.com/aberic/gnomon/balance"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// httpEndpointMaxFailures 默认摘除端点的连续失败次数
+	httpEndpointMaxFailures = 3
+	// httpEndpointCoolDown 默认端点摘除后重新启用的等待时间
+	httpEndpointCoolDown = 30 * time.Second
+)
+
+// ErrHTTPNoEndpoint no http endpoint available
+var ErrHTTPNoEndpoint = errors.New("no http endpoint available")
+
+// HTTPEndpoints 基于 balance.Balancer 的多地址端点集合，设置为 HTTPClient.Endpoints 后替代 BaseURL
+//
+// 端点连续失败（网络错误或5xx应答）达到 MaxFailures 次后被摘除，经过 CoolDown 后重新启用，重新启用后首次请求再失败将立即被摘除；
+// 全部端点均被摘除时仍选择最早被摘除的端点，避免请求全部失败
+//
+// 连接端点失败时请求未被发送，将自动切换至其它端点，请求内容不可重放时除外
+type HTTPEndpoints struct {
+	MaxFailures int           // 摘除端点的连续失败次数，0表示3
+	CoolDown    time.Duration // 端点摘除后重新启用的等待时间，0表示30秒
+
+	balancer  balance.Balancer
+	endpoints []*httpEndpoint
+	lock      sync.Mutex
+}
+
+// httpEndpoint 单个端点及其状态
+type httpEndpoint struct {
+	url      string    // 基础地址
+	failures int       // 连续失败次数
+	ejected  time.Time // 摘除时间，零值表示未摘除
+}
+
+// NewHTTPEndpoints 新建端点集合
+//
+// class 负载模型，如 balance.Round
+func NewHTTPEndpoints(class balance.Class) *HTTPEndpoints {
+	return &HTTPEndpoints{balancer: balance.NewBalance(class)}
+}
+
+// Add 新增端点
+//
+// baseURL 端点基础地址，如“http://10.0.0.1:8080/api”
+//
+// weight 负载权重，负载模型选择权重模型时有效，小于2时按1计
+func (he *HTTPEndpoints) Add(baseURL string, weight int) *HTTPEndpoints {
+	endpoint := &httpEndpoint{url: baseURL}
+	defer he.lock.Unlock()
+	he.lock.Lock()
+	he.endpoints = append(he.endpoints, endpoint)
+	he.balancer.Add(endpoint)
+	if weight > 1 {
+		he.balancer.Weight(endpoint, weight)
+	}
+	return he
+}
+
+// Available 当前未被摘除的端点基础地址
+func (he *HTTPEndpoints) Available() []string {
+	now := time.Now()
+	defer he.lock.Unlock()
+	he.lock.Lock()
+	var urls []string
+	for _, endpoint := range he.endpoints {
+		if he.available(endpoint, now) {
+			urls = append(urls, endpoint.url)
+		}
+	}
+	return urls
+}
+
+// maxFailures 摘除端点的连续失败次数
+func (he *HTTPEndpoints) maxFailures() int {
+	if he.MaxFailures > 0 {
+		return he.MaxFailures
+	}
+	return httpEndpointMaxFailures
+}
+
+// coolDown 端点摘除后重新启用的等待时间
+func (he *HTTPEndpoints) coolDown() time.Duration {
+	if he.CoolDown > 0 {
+		return he.CoolDown
+	}
+	return httpEndpointCoolDown
+}
+
+// available 端点是否未被摘除或已达到重新启用的时间，需持有锁
+func (he *HTTPEndpoints) available(endpoint *httpEndpoint, now time.Time) bool {
+	return endpoint.ejected.IsZero() || now.Sub(endpoint.ejected) >= he.coolDown()
+}
+
+// acquire 通过负载均衡器选择端点，跳过已摘除及exclude中的端点
+//
+// 负载均衡器并非协程安全，调用须持有锁
+func (he *HTTPEndpoints) acquire(exclude map[*httpEndpoint]bool) (*httpEndpoint, error) {
+	defer he.lock.Unlock()
+	he.lock.Lock()
+	count := len(he.endpoints)
+	if count == 0 {
+		return nil, ErrHTTPNoEndpoint
+	}
+	now := time.Now()
+	for index := 0; index < 2*count; index++ {
+		obj, err := he.balancer.Acquire()
+		if nil != err {
+			return nil, err
+		}
+		endpoint := obj.(*httpEndpoint)
+		if !exclude[endpoint] && he.available(endpoint, now) {
+			return endpoint, nil
+		}
+	}
+	var earliest *httpEndpoint
+	for _, endpoint := range he.endpoints {
+		if exclude[endpoint] {
+			continue
+		}
+		if he.available(endpoint, now) {
+			return endpoint, nil
+		}
+		if nil == earliest || endpoint.ejected.Before(earliest.ejected) {
+			earliest = endpoint
+		}
+	}
+	if nil == earliest {
+		return nil, ErrHTTPNoEndpoint
+	}
+	return earliest, nil
+}
+
+// report 记录端点的请求结果
+func (he *HTTPEndpoints) report(endpoint *httpEndpoint, success bool) {
+	defer he.lock.Unlock()
+	he.lock.Lock()
+	if success {
+		endpoint.failures = 0
+		endpoint.ejected = time.Time{}
+		return
+	}
+	endpoint.failures++
+	if !endpoint.ejected.IsZero() || endpoint.failures >= he.maxFailures() {
+		endpoint.ejected = time.Now()
+	}
+}
+
+// httpDialError 是否为建立连接失败的错误，此时请求未被发送
+func httpDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
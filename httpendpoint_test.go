@@ -0,0 +1,164 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gnomon
+
+import (
+	"fmt"
+	"github.com/aberic/gnomon/balance"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// httpEndpointServer 应答自身名称的测试服务
+func httpEndpointServer(name string, status int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(name))
+	}))
+}
+
+func TestHTTPEndpointsRound(t *testing.T) {
+	one := httpEndpointServer("one", http.StatusOK)
+	defer one.Close()
+	two := httpEndpointServer("two", http.StatusOK)
+	defer two.Close()
+	client := NewHTTPClient("")
+	client.Endpoints = NewHTTPEndpoints(balance.Round).Add(one.URL, 1).Add(two.URL, 1)
+	counts := map[string]int{}
+	for index := 0; index < 4; index++ {
+		data, err := client.Get("/name").Do().Bytes()
+		if nil != err {
+			t.Fatal(err)
+		}
+		counts[string(data)]++
+	}
+	if counts["one"] != 2 || counts["two"] != 2 {
+		t.Fatalf("unexpected distribution %v", counts)
+	}
+}
+
+func TestHTTPEndpointsFailover(t *testing.T) {
+	live := httpEndpointServer("live", http.StatusOK)
+	defer live.Close()
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	endpoints := NewHTTPEndpoints(balance.Round).Add(dead.URL, 1).Add(live.URL, 1)
+	endpoints.MaxFailures = 2
+	client := NewHTTPClient("")
+	client.Endpoints = endpoints
+	for index := 0; index < 4; index++ {
+		data, err := client.Post("/name").Bytes("text/plain", []byte("payload")).Do().Bytes()
+		if nil != err {
+			t.Fatal(err)
+		}
+		if string(data) != "live" {
+			t.Fatalf("expected live, got %q", data)
+		}
+	}
+	if available := endpoints.Available(); len(available) != 1 || available[0] != live.URL {
+		t.Fatalf("expected only live endpoint, got %v", available)
+	}
+	// 请求内容不可重放时不切换端点
+	endpoints = NewHTTPEndpoints(balance.Round).Add(dead.URL, 1).Add(live.URL, 1)
+	client = NewHTTPClient("")
+	client.Endpoints = endpoints
+	if _, err := client.Post("/name").Body("text/plain", struct{ *strings.Reader }{strings.NewReader("stream")}).Send(); nil == err {
+		t.Fatal("expected dial error for non-replayable body")
+	}
+}
+
+func TestHTTPEndpointsEject(t *testing.T) {
+	good := httpEndpointServer("good", http.StatusOK)
+	defer good.Close()
+	bad := httpEndpointServer("bad", http.StatusInternalServerError)
+	defer bad.Close()
+	endpoints := NewHTTPEndpoints(balance.Round).Add(good.URL, 1).Add(bad.URL, 1)
+	endpoints.MaxFailures = 2
+	endpoints.CoolDown = 50 * time.Millisecond
+	client := NewHTTPClient("")
+	client.Endpoints = endpoints
+	for index := 0; index < 4; index++ {
+		_ = client.Get("/").Do()
+	}
+	if available := endpoints.Available(); len(available) != 1 || available[0] != good.URL {
+		t.Fatalf("expected bad endpoint ejected, got %v", available)
+	}
+	for index := 0; index < 3; index++ {
+		if data, _ := client.Get("/").Do().Bytes(); string(data) != "good" {
+			t.Fatalf("expected good, got %q", data)
+		}
+	}
+	time.Sleep(60 * time.Millisecond)
+	if available := endpoints.Available(); len(available) != 2 {
+		t.Fatalf("expected bad endpoint readmitted, got %v", available)
+	}
+	// 重新启用后首次失败即被摘除
+	for index := 0; index < 2; index++ {
+		_ = client.Get("/").Do()
+	}
+	if available := endpoints.Available(); len(available) != 1 {
+		t.Fatalf("expected bad endpoint ejected again, got %v", available)
+	}
+}
+
+func TestHTTPEndpointsAllEjected(t *testing.T) {
+	bad := httpEndpointServer("bad", http.StatusServiceUnavailable)
+	defer bad.Close()
+	endpoints := NewHTTPEndpoints(balance.Round).Add(bad.URL, 1)
+	endpoints.MaxFailures = 1
+	client := NewHTTPClient("")
+	client.Endpoints = endpoints
+	for index := 0; index < 2; index++ {
+		resp := client.Get("/").Do()
+		if httpErr, ok := resp.Err().(*HTTPError); !ok || httpErr.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("expected 503, got %v", resp.Err())
+		}
+	}
+	if available := endpoints.Available(); len(available) != 0 {
+		t.Fatalf("expected no available endpoint, got %v", available)
+	}
+	if _, err := NewHTTPEndpoints(balance.Round).acquire(nil); err != ErrHTTPNoEndpoint {
+		t.Fatalf("expected ErrHTTPNoEndpoint, got %v", err)
+	}
+}
+
+func TestHTTPEndpointsConcurrent(t *testing.T) {
+	for _, class := range []balance.Class{balance.Round, balance.Random, balance.Hash, balance.Smooth} {
+		endpoints := NewHTTPEndpoints(class).Add("http://10.0.0.1", 1).Add("http://10.0.0.2", 2)
+		var wg sync.WaitGroup
+		for index := 0; index < 32; index++ {
+			wg.Add(1)
+			go func(index int) {
+				defer wg.Done()
+				if index%8 == 0 {
+					endpoints.Add(fmt.Sprintf("http://10.0.1.%d", index), index/8+1)
+				}
+				for count := 0; count < 100; count++ {
+					if endpoint, err := endpoints.acquire(nil); nil == err {
+						endpoints.report(endpoint, count%3 != 0)
+					}
+				}
+			}(index)
+		}
+		wg.Wait()
+		if count := len(endpoints.endpoints); count != 6 {
+			t.Errorf("class %d endpoints = %d", class, count)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gnomon
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// httpMultipartContentType 附件未指定内容类型时的默认内容类型
+const httpMultipartContentType = "application/octet-stream"
+
+// HTTPMultipart multipart/form-data 请求内容，通过 HTTPRequest.Multipart 设置
+//
+// 各部分在发送时经 io.Pipe 按顺序流式写入，文件内容不会读取至内存，发送结束后自动关闭已打开的文件；
+// 全部部分的长度已知（普通参数、文件及字节数组）时设置请求的Content-Length，否则以chunked方式发送
+type HTTPMultipart struct {
+	boundary string
+	parts    []*httpMultipartPart
+}
+
+// httpMultipartPart 表单的一个部分
+type httpMultipartPart struct {
+	name        string
+	fileName    string // 附件名，为空表示普通参数
+	contentType string
+	open        func() (io.Reader, int64, error) // 获取该部分内容及长度，长度未知时为-1
+	replayable  bool                             // 内容是否可重复获取
+	owned       bool                             // 内容是否由 open 打开，写入后须关闭
+}
+
+// NewHTTPMultipart 新建 multipart/form-data 请求内容
+func NewHTTPMultipart() *HTTPMultipart {
+	return &HTTPMultipart{boundary: multipart.NewWriter(ioutil.Discard).Boundary()}
+}
+
+// ContentType 请求内容类型，包含分隔符
+func (hm *HTTPMultipart) ContentType() string {
+	return StringBuild("multipart/form-data; boundary=", hm.boundary)
+}
+
+// Field 新增普通参数
+func (hm *HTTPMultipart) Field(name, value string) *HTTPMultipart {
+	return hm.add(&httpMultipartPart{name: name, replayable: true, open: func() (io.Reader, int64, error) {
+		return strings.NewReader(value), int64(len(value)), nil
+	}})
+}
+
+// File 新增文件附件，附件名为文件名，文件在发送时打开并在写入后关闭
+//
+// contentType 附件内容类型，为空时为“application/octet-stream”
+func (hm *HTTPMultipart) File(name, filePath, contentType string) *HTTPMultipart {
+	return hm.add(&httpMultipartPart{name: name, fileName: filepath.Base(filePath), contentType: contentType, replayable: true, owned: true,
+		open: func() (io.Reader, int64, error) {
+			file, err := os.Open(filePath)
+			if nil != err {
+				return nil, 0, err
+			}
+			info, err := file.Stat()
+			if nil != err {
+				_ = file.Close()
+				return nil, 0, err
+			}
+			return file, info.Size(), nil
+		}})
+}
+
+// Reader 新增附件，内容读取自reader
+//
+// reader 仅能读取一次，包含该部分的请求不会被重试或切换端点；reader由调用方负责关闭
+//
+// contentType 附件内容类型，为空时为“application/octet-stream”
+func (hm *HTTPMultipart) Reader(name, fileName, contentType string, reader io.Reader) *HTTPMultipart {
+	return hm.add(&httpMultipartPart{name: name, fileName: fileName, contentType: contentType,
+		open: func() (io.Reader, int64, error) {
+			return reader, -1, nil
+		}})
+}
+
+// Bytes 新增附件，内容为data
+//
+// contentType 附件内容类型，为空时为“application/octet-stream”
+func (hm *HTTPMultipart) Bytes(name, fileName, contentType string, data []byte) *HTTPMultipart {
+	return hm.add(&httpMultipartPart{name: name, fileName: fileName, contentType: contentType, replayable: true,
+		open: func() (io.Reader, int64, error) {
+			return bytes.NewReader(data), int64(len(data)), nil
+		}})
+}
+
+// add 新增部分
+func (hm *HTTPMultipart) add(part *httpMultipartPart) *HTTPMultipart {
+	hm.parts = append(hm.parts, part)
+	return hm
+}
+
+// replayable 全部部分是否可重复获取
+func (hm *HTTPMultipart) replayable() bool {
+	for _, part := range hm.parts {
+		if !part.replayable {
+			return false
+		}
+	}
+	return true
+}
+
+// header 该部分的头信息
+func (hmp *httpMultipartPart) header() textproto.MIMEHeader {
+	header := textproto.MIMEHeader{}
+	if StringIsEmpty(hmp.fileName) {
+		header.Set("Content-Disposition", StringBuild(`form-data; name="`, httpMultipartEscape(hmp.name), `"`))
+		return header
+	}
+	header.Set("Content-Disposition",
+		StringBuild(`form-data; name="`, httpMultipartEscape(hmp.name), `"; filename="`, httpMultipartEscape(hmp.fileName), `"`))
+	if StringIsEmpty(hmp.contentType) {
+		header.Set("Content-Type", httpMultipartContentType)
+	} else {
+		header.Set("Content-Type", hmp.contentType)
+	}
+	return header
+}
+
+// httpMultipartEscaper 参数名及附件名转义规则，与 mime/multipart 一致
+var httpMultipartEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// httpMultipartEscape 转义参数名及附件名中的反斜杠及双引号
+func httpMultipartEscape(value string) string {
+	return httpMultipartEscaper.Replace(value)
+}
+
+// httpMultipartBody 流式写入的请求内容
+type httpMultipartBody struct {
+	*io.PipeReader
+	size int64 // 请求内容长度，未知时为-1
+}
+
+// open 获取全部部分的内容并开始在新协程中写入，任一部分获取失败（如文件不存在）时直接返回错误
+func (hm *HTTPMultipart) open() (io.Reader, error) {
+	var (
+		readers = make([]io.Reader, len(hm.parts))
+		sizes   = make([]int64, len(hm.parts))
+		err     error
+	)
+	for index, part := range hm.parts {
+		if readers[index], sizes[index], err = part.open(); nil != err {
+			httpMultipartClose(hm.parts[:index], readers)
+			return nil, err
+		}
+	}
+	size, err := hm.size(sizes)
+	if nil != err {
+		httpMultipartClose(hm.parts, readers)
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(hm.write(pw, readers))
+	}()
+	return &httpMultipartBody{PipeReader: pr, size: size}, nil
+}
+
+// write 按顺序写入全部部分，结束后关闭已打开的文件
+func (hm *HTTPMultipart) write(writer io.Writer, readers []io.Reader) error {
+	defer httpMultipartClose(hm.parts, readers)
+	bodyWriter := multipart.NewWriter(writer)
+	if err := bodyWriter.SetBoundary(hm.boundary); nil != err {
+		return err
+	}
+	for index, part := range hm.parts {
+		partWriter, err := bodyWriter.CreatePart(part.header())
+		if nil != err {
+			return err
+		}
+		if _, err = io.Copy(partWriter, readers[index]); nil != err {
+			return err
+		}
+	}
+	return bodyWriter.Close()
+}
+
+// size 计算请求内容长度，任一部分长度未知时为-1
+func (hm *HTTPMultipart) size(sizes []int64) (int64, error) {
+	counter := &httpCountWriter{}
+	bodyWriter := multipart.NewWriter(counter)
+	if err := bodyWriter.SetBoundary(hm.boundary); nil != err {
+		return 0, err
+	}
+	var total int64
+	for index, part := range hm.parts {
+		if sizes[index] < 0 {
+			return -1, nil
+		}
+		if _, err := bodyWriter.CreatePart(part.header()); nil != err {
+			return 0, err
+		}
+		total += sizes[index]
+	}
+	if err := bodyWriter.Close(); nil != err {
+		return 0, err
+	}
+	return total + counter.count, nil
+}
+
+// httpMultipartClose 关闭由 HTTPMultipart.File 打开的文件，调用方通过 HTTPMultipart.Reader 传入的读取器不会被关闭
+func httpMultipartClose(parts []*httpMultipartPart, readers []io.Reader) {
+	for index, part := range parts {
+		if closer, ok := readers[index].(io.Closer); ok && part.owned {
+			_ = closer.Close()
+		}
+	}
+}
+
+// httpCountWriter 仅统计写入字节数
+type httpCountWriter struct {
+	count int64
+}
+
+func (hcw *httpCountWriter) Write(p []byte) (int, error) {
+	hcw.count += int64(len(p))
+	return len(p), nil
+}
+
+// Multipart 设置 multipart/form-data 请求内容，各部分在发送时流式写入
+//
+// 包含 HTTPMultipart.Reader 部分时请求内容仅能发送一次，不会被重试
+func (hr *HTTPRequest) Multipart(form *HTTPMultipart) *HTTPRequest {
+	hr.BodyFunc(form.ContentType(), form.open)
+	hr.replayable = form.replayable()
+	return hr
+}
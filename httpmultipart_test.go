@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gnomon
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// httpMultipartEcho 以“名称|附件名|内容类型|内容”逐行应答各部分，并在响应头中返回请求内容长度
+func httpMultipartEcho(w http.ResponseWriter, r *http.Request) {
+	reader, err := r.MultipartReader()
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var lines []string
+	for {
+		part, err := reader.NextPart()
+		if nil != err {
+			break
+		}
+		data, _ := ioutil.ReadAll(part)
+		lines = append(lines, strings.Join([]string{part.FormName(), part.FileName(), part.Header.Get("Content-Type"), string(data)}, "|"))
+	}
+	body := strings.Join(lines, "\n")
+	w.Header().Set("X-Length", strconv.FormatInt(r.ContentLength, 10))
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	_, _ = w.Write([]byte(body))
+}
+
+func TestHTTPMultipart(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(httpMultipartEcho))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "gnomon")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "artifact.tar")
+	if err = ioutil.WriteFile(file, []byte(strings.Repeat("a", 100000)), 0644); nil != err {
+		t.Fatal(err)
+	}
+
+	var uploaded, uploadTotal, downloaded, downloadTotal int64
+	form := NewHTTPMultipart().
+		Field("version", "1.0").
+		File("artifact", file, "application/x-tar").
+		Bytes("meta", `na"me.json`, "application/json", []byte(`{"a":1}`))
+	resp := NewHTTPClient(server.URL).Post("/").Multipart(form).
+		UploadProgress(func(transferred, total int64) { uploaded, uploadTotal = transferred, total }).
+		DownloadProgress(func(transferred, total int64) { downloaded, downloadTotal = transferred, total }).
+		Do()
+	data, err := resp.Bytes()
+	if nil != err {
+		t.Fatal(err)
+	}
+	expect := strings.Join([]string{
+		"version|||1.0",
+		"artifact|artifact.tar|application/x-tar|" + strings.Repeat("a", 100000),
+		`meta|na"me.json|application/json|{"a":1}`,
+	}, "\n")
+	if string(data) != expect {
+		t.Fatalf("unexpected parts %q", data)
+	}
+	length, _ := strconv.ParseInt(resp.Header.Get("X-Length"), 10, 64)
+	if length <= 100000 || uploaded != length || uploadTotal != length {
+		t.Errorf("length = %d, uploaded = %d/%d", length, uploaded, uploadTotal)
+	}
+	if downloaded != int64(len(data)) || downloadTotal != int64(len(data)) {
+		t.Errorf("downloaded = %d/%d, expected %d", downloaded, downloadTotal, len(data))
+	}
+
+	// 读取器部分长度未知，以chunked方式发送
+	data, err = NewHTTPClient(server.URL).Post("/").
+		Multipart(NewHTTPMultipart().Reader("log", "build.log", "", strings.NewReader("line"))).
+		UploadProgress(func(transferred, total int64) { uploaded, uploadTotal = transferred, total }).
+		Do().Bytes()
+	if nil != err {
+		t.Fatal(err)
+	}
+	if string(data) != "log|build.log|application/octet-stream|line" || uploadTotal != -1 {
+		t.Errorf("reader part = %q, total = %d", data, uploadTotal)
+	}
+
+	// 调用方传入的文件由调用方关闭
+	log, err := os.Open(file)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer func() { _ = log.Close() }()
+	if err = NewHTTPClient(server.URL).Post("/").Multipart(NewHTTPMultipart().Reader("log", "build.log", "", log)).Do().Err(); nil != err {
+		t.Fatal(err)
+	}
+	if _, err = log.Seek(0, io.SeekStart); nil != err {
+		t.Errorf("caller file closed: %v", err)
+	}
+}
+
+func TestHTTPMultipartRetry(t *testing.T) {
+	var (
+		lock   sync.Mutex
+		bodies []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		lock.Lock()
+		bodies = append(bodies, string(body))
+		count := len(bodies)
+		lock.Unlock()
+		if count == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	client := NewHTTPClient(server.URL)
+	client.Retry = &HTTPRetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	if err := client.Put("/").Multipart(NewHTTPMultipart().Bytes("f", "f.bin", "", []byte("payload"))).Do().Err(); nil != err {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 || bodies[0] != bodies[1] || !strings.Contains(bodies[1], "payload") {
+		t.Fatalf("expected replayed multipart body, got %q", bodies)
+	}
+
+	bodies = nil
+	err := client.Put("/").Multipart(NewHTTPMultipart().Reader("f", "f.bin", "", strings.NewReader("payload"))).Do().Err()
+	if httpErr, ok := err.(*HTTPError); !ok || httpErr.StatusCode != http.StatusServiceUnavailable || len(bodies) != 1 {
+		t.Fatalf("expected single attempt for reader part, got %v after %d", err, len(bodies))
+	}
+}
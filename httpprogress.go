@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gnomon
+
+import (
+	"io"
+)
+
+// HTTPProgress 传输进度回调
+//
+// transferred 已传输字节数
+//
+// total 总字节数，未知时为-1
+type HTTPProgress func(transferred, total int64)
+
+// httpProgressReader 读取时回调传输进度
+type httpProgressReader struct {
+	io.ReadCloser
+	progress    HTTPProgress
+	transferred int64
+	total       int64
+}
+
+func (hpr *httpProgressReader) Read(p []byte) (int, error) {
+	n, err := hpr.ReadCloser.Read(p)
+	if n > 0 {
+		hpr.transferred += int64(n)
+		hpr.progress(hpr.transferred, hpr.total)
+	}
+	return n, err
+}
+
+// newHTTPProgressReader 包装读取器，total小于等于0时视为未知
+func newHTTPProgressReader(reader io.ReadCloser, total int64, progress HTTPProgress) io.ReadCloser {
+	if total <= 0 {
+		total = -1
+	}
+	return &httpProgressReader{ReadCloser: reader, progress: progress, total: total}
+}
+
+// UploadProgress 设置请求内容的发送进度回调，回调在发送请求内容的协程中调用
+//
+// 重试或切换端点时进度自0重新开始
+func (hr *HTTPRequest) UploadProgress(progress HTTPProgress) *HTTPRequest {
+	hr.uploadProgress = progress
+	return hr
+}
+
+// DownloadProgress 设置应答内容的读取进度回调，回调在读取应答内容的协程中调用
+func (hr *HTTPRequest) DownloadProgress(progress HTTPProgress) *HTTPRequest {
+	hr.downloadProgress = progress
+	return hr
+}
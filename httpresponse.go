@@ -0,0 +1,186 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gnomon
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/vmihailenco/msgpack"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	// HTTPDefaultMaxResponseBytes 默认允许读取的最大应答字节数
+	HTTPDefaultMaxResponseBytes = 32 << 20
+	// httpDrainBytes 应答超出上限时为复用连接最多继续丢弃的字节数
+	httpDrainBytes = 256 << 10
+	// httpErrorBodyLength 错误信息中展示的应答内容最大长度
+	httpErrorBodyLength = 256
+)
+
+var (
+	// ErrHTTPContentType unsupported response content type
+	ErrHTTPContentType = errors.New("unsupported response content type")
+	// ErrHTTPResponseTooLarge http response body too large
+	ErrHTTPResponseTooLarge = errors.New("http response body too large")
+)
+
+// HTTPError 非2xx应答，包含状态码、响应头及应答内容
+type HTTPError struct {
+	StatusCode int         // 状态码，如 http.StatusNotFound
+	Status     string      // 状态描述，如“404 Not Found”
+	Header     http.Header // 响应头
+	Body       []byte      // 应答内容
+	Model      interface{} // 通过 HTTPRequest.ErrorModel 设置并解析的错误应答结构体，解析失败时为nil
+}
+
+func (he *HTTPError) Error() string {
+	body := string(he.Body)
+	if len(body) > httpErrorBodyLength {
+		body = StringBuild(body[:httpErrorBodyLength], "...")
+	}
+	if StringIsEmpty(body) {
+		return StringBuild("http ", he.Status)
+	}
+	return StringBuild("http ", he.Status, ": ", body)
+}
+
+// Decode 按响应头中的内容类型解析错误应答内容至obj
+func (he *HTTPError) Decode(obj interface{}) error {
+	return httpDecode(he.Header.Get("Content-Type"), he.Body, obj)
+}
+
+// HTTPResponse 已读取并关闭的应答，通过 HTTPRequest.Do 获取
+//
+// 请求失败、应答超出最大字节数或状态码非2xx时 Err 返回对应错误，其中非2xx状态码对应 *HTTPError
+type HTTPResponse struct {
+	*http.Response        // 原始应答，请求失败时为nil，其Body已关闭
+	data           []byte // 应答内容
+	err            error
+}
+
+// Err 请求或应答的错误
+func (hr *HTTPResponse) Err() error {
+	return hr.err
+}
+
+// Bytes 获取应答内容
+func (hr *HTTPResponse) Bytes() ([]byte, error) {
+	return hr.data, hr.err
+}
+
+// Decode 按应答的内容类型解析应答内容至obj，支持JSON、XML、Yaml、MsgPack及ProtoBuf，存在错误时直接返回该错误
+//
+// 应答内容为空（如 204 No Content）时不解析，obj保持不变
+func (hr *HTTPResponse) Decode(obj interface{}) error {
+	if nil != hr.err {
+		return hr.err
+	}
+	if len(hr.data) == 0 {
+		return nil
+	}
+	return httpDecode(hr.Header.Get("Content-Type"), hr.data, obj)
+}
+
+// Do 发起请求，读取并关闭应答内容，可通过 HTTPResponse.Decode 解析应答
+func (hr *HTTPRequest) Do() *HTTPResponse {
+	resp, err := hr.Send()
+	if nil != err {
+		return &HTTPResponse{err: err}
+	}
+	data, err := httpReadAll(resp.Body, hr.maxResponseBytes())
+	_ = resp.Body.Close()
+	response := &HTTPResponse{Response: resp, data: data, err: err}
+	if nil == err && (resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices) {
+		httpErr := &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header, Body: data}
+		if nil != hr.errorModel && len(data) > 0 && nil == httpErr.Decode(hr.errorModel) {
+			httpErr.Model = hr.errorModel
+		}
+		response.err = httpErr
+	}
+	return response
+}
+
+// ErrorModel 设置非2xx应答的解析结构体，解析成功时可通过 HTTPError.Model 获取
+func (hr *HTTPRequest) ErrorModel(model interface{}) *HTTPRequest {
+	hr.errorModel = model
+	return hr
+}
+
+// MaxResponseBytes 设置本次请求允许读取的最大应答字节数，覆盖客户端配置
+func (hr *HTTPRequest) MaxResponseBytes(maxBytes int64) *HTTPRequest {
+	hr.maxBytes = maxBytes
+	return hr
+}
+
+// maxResponseBytes 本次请求允许读取的最大应答字节数，小于0表示不限
+func (hr *HTTPRequest) maxResponseBytes() int64 {
+	if hr.maxBytes != 0 {
+		return hr.maxBytes
+	}
+	if hr.client.MaxResponseBytes != 0 {
+		return hr.client.MaxResponseBytes
+	}
+	return HTTPDefaultMaxResponseBytes
+}
+
+// httpReadAll 读取应答内容，超出maxBytes时丢弃有限的剩余内容并返回 ErrHTTPResponseTooLarge
+func httpReadAll(body io.Reader, maxBytes int64) ([]byte, error) {
+	if maxBytes < 0 {
+		return ioutil.ReadAll(body)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(body, maxBytes+1))
+	if nil != err {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		_, _ = io.CopyN(ioutil.Discard, body, httpDrainBytes)
+		return nil, ErrHTTPResponseTooLarge
+	}
+	return data, nil
+}
+
+// httpDecode 按内容类型解析数据至obj，支持“application/problem+json”等结构化后缀
+func httpDecode(contentType string, data []byte, obj interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if nil != err {
+		return ErrHTTPContentType
+	}
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return json.Unmarshal(data, obj)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return xml.Unmarshal(data, obj)
+	case mediaType == "application/x-yaml" || mediaType == "application/yaml" || mediaType == "text/yaml":
+		return yaml.NewDecoder(bytes.NewReader(data)).Decode(obj)
+	case mediaType == "application/x-msgpack" || mediaType == "application/msgpack":
+		return msgpack.Unmarshal(data, obj)
+	case mediaType == "application/x-protobuf" || mediaType == "application/protobuf":
+		pm, ok := obj.(proto.Message)
+		if !ok {
+			return ErrHTTPContentType
+		}
+		return proto.Unmarshal(data, pm)
+	}
+	return ErrHTTPContentType
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gnomon
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/vmihailenco/msgpack"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type httpProblem struct {
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+func TestHTTPResponseDecode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_, _ = w.Write([]byte(`{"one":"1","ones":true,"one_go":3}`))
+		case "/xml":
+			w.Header().Set("Content-Type", "text/xml")
+			_, _ = w.Write([]byte(`<TestOne><One>x</One></TestOne>`))
+		case "/yaml":
+			w.Header().Set("Content-Type", "application/x-yaml")
+			_, _ = w.Write([]byte("one: y\nonego: 4\n"))
+		case "/msgpack":
+			w.Header().Set("Content-Type", "application/x-msgpack")
+			data, _ := msgpack.Marshal(&TestOne{One: "m"})
+			_, _ = w.Write(data)
+		case "/proto":
+			w.Header().Set("Content-Type", "application/x-protobuf")
+			data, _ := proto.Marshal(&wrappers.StringValue{Value: "p"})
+			_, _ = w.Write(data)
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		case "/text":
+			_, _ = w.Write([]byte("plain"))
+		case "/large":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`"` + strings.Repeat("a", 2048) + `"`))
+		default:
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"title":"not found","detail":"` + r.URL.Path + `"}`))
+		}
+	}))
+	defer server.Close()
+	client := NewHTTPClient(server.URL)
+
+	one := &TestOne{}
+	if err := client.Get("/json").Do().Decode(one); nil != err || one.One != "1" || !one.Ones || one.OneGo != 3 {
+		t.Errorf("json = %+v, %v", one, err)
+	}
+	one = &TestOne{}
+	if err := client.Get("/xml").Do().Decode(one); nil != err || one.One != "x" {
+		t.Errorf("xml = %+v, %v", one, err)
+	}
+	one = &TestOne{}
+	if err := client.Get("/yaml").Do().Decode(one); nil != err || one.One != "y" || one.OneGo != 4 {
+		t.Errorf("yaml = %+v, %v", one, err)
+	}
+	one = &TestOne{}
+	if err := client.Get("/msgpack").Do().Decode(one); nil != err || one.One != "m" {
+		t.Errorf("msgpack = %+v, %v", one, err)
+	}
+	value := &wrappers.StringValue{}
+	if err := client.Get("/proto").Do().Decode(value); nil != err || value.Value != "p" {
+		t.Errorf("proto = %v, %v", value, err)
+	}
+	if err := client.Get("/proto").Do().Decode(one); err != ErrHTTPContentType {
+		t.Errorf("proto into struct = %v", err)
+	}
+	if err := client.Get("/empty").Do().Decode(one); nil != err {
+		t.Errorf("empty = %v", err)
+	}
+	if err := client.Get("/text").Do().Decode(one); err != ErrHTTPContentType {
+		t.Errorf("text = %v", err)
+	}
+	if data, err := client.Get("/text").Do().Bytes(); nil != err || string(data) != "plain" {
+		t.Errorf("bytes = %q, %v", data, err)
+	}
+
+	var large string
+	if err := client.Get("/large").MaxResponseBytes(1024).Do().Decode(&large); err != ErrHTTPResponseTooLarge {
+		t.Errorf("large = %v", err)
+	}
+	limited := &HTTPClient{BaseURL: server.URL, MaxResponseBytes: 1024}
+	if err := limited.Get("/large").Do().Err(); err != ErrHTTPResponseTooLarge {
+		t.Errorf("client limit = %v", err)
+	}
+	if err := limited.Get("/large").MaxResponseBytes(-1).Do().Decode(&large); nil != err || len(large) != 2048 {
+		t.Errorf("unlimited = %d, %v", len(large), err)
+	}
+
+	problem := &httpProblem{}
+	resp := client.Get("/missing").ErrorModel(problem).Do()
+	httpErr, ok := resp.Err().(*HTTPError)
+	if !ok || httpErr.StatusCode != http.StatusNotFound || httpErr.Model != problem || problem.Detail != "/missing" ||
+		httpErr.Header.Get("Content-Type") != "application/problem+json" || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("http error = %#v", resp.Err())
+	}
+	if !strings.HasPrefix(httpErr.Error(), `http 404 Not Found: {"title":"not found"`) {
+		t.Errorf("error = %s", httpErr.Error())
+	}
+	decoded := &httpProblem{}
+	if err := httpErr.Decode(decoded); nil != err || decoded.Title != "not found" {
+		t.Errorf("decode error body = %+v, %v", decoded, err)
+	}
+	if err := client.Get("/missing").Do().Decode(one); nil == err {
+		t.Error("expected http error")
+	}
+	if resp := NewHTTPClient("").Get("http://127.0.0.1:0/").Do(); nil == resp.Err() || nil != resp.Response {
+		t.Errorf("dial error = %v", resp.Err())
+	}
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gnomon
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IdempotencyKeyHeader 幂等键请求头，设置后非幂等请求方法（如POST、PATCH）同样允许重试
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	// httpRetryBaseDelay 默认首次重试的等待时间
+	httpRetryBaseDelay = 100 * time.Millisecond
+	// httpRetryMaxDelay 默认最大等待时间
+	httpRetryMaxDelay = 10 * time.Second
+)
+
+// httpRetryStatuses 默认重试的状态码
+var httpRetryStatuses = []int{
+	http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+}
+
+// HTTPRetrier 重试策略
+type HTTPRetrier interface {
+	// Retry 判断第attempt次（从1开始）请求后是否重试及重试前的等待时间
+	//
+	// resp 与 err 为本次请求的结果，err不为nil时resp为nil；请求内容不可重放或请求的上下文已结束时不会调用
+	Retry(attempt int, req *http.Request, resp *http.Response, err error) (time.Duration, bool)
+}
+
+// HTTPRetryPolicy 指数退避重试策略
+//
+// 幂等请求方法（GET、HEAD、OPTIONS、TRACE、PUT及DELETE）或设置了“Idempotency-Key”的请求在网络错误或应答指定状态码时重试，
+// 第n次重试前等待 BaseDelay*2^(n-1)，不超过 MaxDelay，并按 Jitter 随机缩短；应答包含“Retry-After”时以其为准
+type HTTPRetryPolicy struct {
+	MaxAttempts   int                                       // 最多请求次数，包括首次请求，小于2表示不重试
+	BaseDelay     time.Duration                             // 首次重试的等待时间，0表示100毫秒
+	MaxDelay      time.Duration                             // 最大等待时间，0表示10秒，“Retry-After”超出该值时不再重试
+	Jitter        float64                                   // 等待时间随机缩短的最大比例，取值0~1，如0.5表示等待时间在[50%,100%]间随机
+	RetryStatuses []int                                     // 重试的状态码，为空时重试429、502、503及504
+	Retryable     func(resp *http.Response, err error) bool // 自定义是否重试的判断，设置后替代网络错误及状态码的判断，幂等性判断依然生效
+}
+
+// DefaultHTTPRetryPolicy 默认重试策略，最多请求3次，等待时间自100毫秒开始指数增长并随机缩短至多一半
+func DefaultHTTPRetryPolicy() *HTTPRetryPolicy {
+	return &HTTPRetryPolicy{MaxAttempts: 3, Jitter: 0.5}
+}
+
+// Retry 实现 HTTPRetrier
+func (hrp *HTTPRetryPolicy) Retry(attempt int, req *http.Request, resp *http.Response, err error) (time.Duration, bool) {
+	if attempt >= hrp.MaxAttempts || !httpIdempotent(req) || !hrp.retryable(resp, err) {
+		return 0, false
+	}
+	maxDelay := hrp.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = httpRetryMaxDelay
+	}
+	if nil != resp {
+		if retryAfter, ok := httpRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			if retryAfter > maxDelay {
+				return 0, false
+			}
+			return retryAfter, true
+		}
+	}
+	return hrp.backoff(attempt, maxDelay), true
+}
+
+// retryable 本次请求结果是否需要重试
+func (hrp *HTTPRetryPolicy) retryable(resp *http.Response, err error) bool {
+	if nil != hrp.Retryable {
+		return hrp.Retryable(resp, err)
+	}
+	if nil != err {
+		return true
+	}
+	statuses := hrp.RetryStatuses
+	if len(statuses) == 0 {
+		statuses = httpRetryStatuses
+	}
+	for _, status := range statuses {
+		if resp.StatusCode == status {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff 第attempt次请求后的退避等待时间
+func (hrp *HTTPRetryPolicy) backoff(attempt int, maxDelay time.Duration) time.Duration {
+	delay := hrp.BaseDelay
+	if delay <= 0 {
+		delay = httpRetryBaseDelay
+	}
+	for index := 1; index < attempt && delay < maxDelay; index++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	if jitter := hrp.Jitter; jitter > 0 {
+		if jitter > 1 {
+			jitter = 1
+		}
+		delay -= time.Duration(float64(delay) * jitter * rand.Float64())
+	}
+	return delay
+}
+
+// httpIdempotent 请求是否可安全重试
+func httpIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return StringIsNotEmpty(req.Header.Get(IdempotencyKeyHeader))
+}
+
+// httpRetryAfter 解析“Retry-After”，支持秒数及HTTP日期
+func httpRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value = strings.TrimSpace(value); StringIsEmpty(value) {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); nil == err {
+		if seconds < 0 {
+			return 0, false
+		}
+		if seconds > int64(math.MaxInt64/time.Second) { // 避免溢出为负数
+			return math.MaxInt64, true
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); nil == err {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// Retry 设置本次请求的重试策略，覆盖客户端配置
+func (hr *HTTPRequest) Retry(retry HTTPRetrier) *HTTPRequest {
+	hr.retry = retry
+	return hr
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright (c) 2020. Aberic - All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gnomon
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPRetryPolicy(t *testing.T) {
+	var (
+		lock   sync.Mutex
+		bodies []string
+		fails  = 2
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		lock.Lock()
+		bodies = append(bodies, string(body))
+		count := len(bodies)
+		lock.Unlock()
+		switch r.URL.Path {
+		case "/after":
+			if count == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		case "/later":
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		case "/bad":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		default:
+			if count <= fails {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	client := &HTTPClient{BaseURL: server.URL, Retry: &HTTPRetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}}
+	reset := func() {
+		lock.Lock()
+		bodies = nil
+		lock.Unlock()
+	}
+	attempts := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(bodies)
+	}
+
+	if data, err := client.Put("/flaky").Bytes("text/plain", []byte("payload")).Do().Bytes(); nil != err || string(data) != "ok" {
+		t.Fatalf("put = %q, %v", data, err)
+	}
+	if attempts() != 3 || bodies[0] != "payload" || bodies[2] != "payload" {
+		t.Errorf("replayed bodies = %q", bodies)
+	}
+
+	reset()
+	if err := client.Post("/flaky").JSON(&TestOne{One: "1"}).Do().Err(); nil == err || attempts() != 1 {
+		t.Errorf("post without key = %v, attempts = %d", err, attempts())
+	}
+	reset()
+	if err := client.Post("/flaky").Header(IdempotencyKeyHeader, "k1").JSON(&TestOne{One: "1"}).Do().Err(); nil != err || attempts() != 3 {
+		t.Errorf("post with key = %v, attempts = %d", err, attempts())
+	}
+
+	reset()
+	fails = 5
+	httpErr, ok := client.Get("/flaky").Do().Err().(*HTTPError)
+	if !ok || httpErr.StatusCode != http.StatusBadGateway || attempts() != 3 {
+		t.Errorf("exhausted = %v, attempts = %d", httpErr, attempts())
+	}
+
+	reset()
+	if err := client.Get("/after").Do().Err(); nil != err || attempts() != 2 {
+		t.Errorf("retry after = %v, attempts = %d", err, attempts())
+	}
+	reset()
+	if err := client.Get("/later").Do().Err(); nil == err || attempts() != 1 {
+		t.Errorf("retry after too long = %v, attempts = %d", err, attempts())
+	}
+	reset()
+	if err := client.Get("/bad").Do().Err(); nil == err || attempts() != 1 {
+		t.Errorf("non retry status = %v, attempts = %d", err, attempts())
+	}
+
+	reset()
+	stream := io.MultiReader(strings.NewReader("a"), strings.NewReader("b"))
+	if err := client.Put("/flaky").Body("text/plain", stream).Do().Err(); nil == err || attempts() != 1 {
+		t.Errorf("stream body = %v, attempts = %d", err, attempts())
+	}
+	reset()
+	fails = 1
+	calls := 0
+	if err := client.Put("/flaky").BodyFunc("text/plain", func() (io.Reader, error) {
+		calls++
+		return io.MultiReader(strings.NewReader("a"), strings.NewReader("b")), nil
+	}).Do().Err(); nil != err || attempts() != 2 || calls != 2 || bodies[1] != "ab" {
+		t.Errorf("body func = %v, attempts = %d, calls = %d", err, attempts(), calls)
+	}
+
+	reset()
+	fails = 5
+	start := time.Now()
+	slow := &HTTPRetryPolicy{MaxAttempts: 5, BaseDelay: time.Second}
+	if err := client.Get("/flaky").Retry(slow).Timeout(100 * time.Millisecond).Do().Err(); nil == err || time.Since(start) > 900*time.Millisecond {
+		t.Errorf("timeout during backoff = %v after %v", err, time.Since(start))
+	}
+}
+
+func TestHTTPRetryNetwork(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	addr := server.URL
+	server.Close()
+	var errs []error
+	policy := &HTTPRetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Retryable: func(resp *http.Response, err error) bool {
+		errs = append(errs, err)
+		return nil != err
+	}}
+	if err := NewHTTPClient(addr).Get("/").Retry(policy).Do().Err(); nil == err || len(errs) != 2 {
+		t.Errorf("network = %v, retries = %d", err, len(errs))
+	}
+}
+
+func TestHTTPRetryBackoff(t *testing.T) {
+	policy := &HTTPRetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 9: time.Second} {
+		if delay := policy.backoff(attempt, time.Second); delay != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, delay, want)
+		}
+	}
+	policy.Jitter = 0.5
+	for index := 0; index < 100; index++ {
+		if delay := policy.backoff(2, time.Second); delay < 100*time.Millisecond || delay > 200*time.Millisecond {
+			t.Fatalf("jitter = %v", delay)
+		}
+	}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if delay, ok := httpRetryAfter(now.Add(5*time.Second).Format(http.TimeFormat), now); !ok || delay != 5*time.Second {
+		t.Errorf("retry after date = %v, %v", delay, ok)
+	}
+	if _, ok := httpRetryAfter("soon", now); ok {
+		t.Error("invalid retry after accepted")
+	}
+	if delay, ok := httpRetryAfter("99999999999", now); !ok || delay < 0 {
+		t.Errorf("huge retry after = %v, %v", delay, ok)
+	}
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{"Retry-After": {"99999999999"}}}
+	get, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+	if delay, ok := DefaultHTTPRetryPolicy().Retry(1, get, resp, nil); ok {
+		t.Errorf("huge retry after retried after %v", delay)
+	}
+	req, _ := http.NewRequest(http.MethodPatch, "http://localhost", nil)
+	if _, ok := DefaultHTTPRetryPolicy().Retry(1, req, nil, errors.New("reset")); ok {
+		t.Error("patch retried without idempotency key")
+	}
+}